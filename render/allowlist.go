@@ -0,0 +1,94 @@
+package render
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WithAllowlist lets you switch Redact to an allowlist model: all the values
+// are masked, whatever their tags, but the fields tagged `redact:"KEEP"` and
+// the fields whose path matches one of paths, which are rendered as with
+// Render except for their own tagged fields. Strings and numbers are masked
+// entirely, so that the structure of values remains visible: field names,
+// types and lengths. The outputs of type formatters are masked the same way,
+// and masked fields are reported with the SourceAllowlist source.
+//
+// Paths are written as in reports, except for map keys which are written as
+// rendered, e.g. ".User.ID" or `.Limits["max"]`, "[*]" matching any slice
+// index or map key and "*" any field name, e.g. ".Users[*].*". Paths are not
+// matched by RedactValue.
+func WithAllowlist(paths ...string) MarshallerOption {
+	return func(m *Marshaller) error {
+		allowed := m.options.redact.allowedPaths
+		allowed = allowed[:len(allowed):len(allowed)]
+		for _, path := range paths {
+			re, err := compileAllowedPath(path)
+			if err != nil {
+				return errors.Wrapf(err, "invalid allowed path %q", path)
+			}
+			allowed = append(allowed, re)
+		}
+		m.options.redact.allowedPaths = allowed
+		m.options.redact.allowlist = true
+		return nil
+	}
+}
+
+// compileAllowedPath compiles an allowed path pattern into a regexp.
+func compileAllowedPath(path string) (*regexp.Regexp, error) {
+	if !strings.HasPrefix(path, ".") && !strings.HasPrefix(path, "[") {
+		return nil, errors.New("must start with a field or an index")
+	}
+	pattern := regexp.QuoteMeta(path)
+	pattern = strings.ReplaceAll(pattern, `\[\*\]`, `\[[^\]]*\]`)
+	pattern = strings.ReplaceAll(pattern, `\*`, `[^.\[]*`)
+	return regexp.Compile("^" + pattern + "$")
+}
+
+// kept returns true if the field with the redact mode tag, at path, is
+// allowed in clear by the allowlist. An empty path is never allowed.
+func (o *options) kept(tag string, path string) bool {
+	if !o.redact.allowlist {
+		return false
+	}
+	if tag == KEEP {
+		return true
+	}
+	if tag != "" || path == "" {
+		return false
+	}
+	for _, re := range o.redact.allowedPaths {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutAllowlist returns a copy of the options rendering the values kept by
+// the allowlist, whose own tagged fields only are redacted.
+func (o *options) withoutAllowlist() *options {
+	opts := *o
+	opts.redact.allowlist = false
+	return &opts
+}
+
+// recordAllowlist records that the struct field f, of value v, has been
+// masked by the allowlist, if the allowlist is on.
+func (o *options) recordAllowlist(f reflect.StructField, v reflect.Value) {
+	if o.recorder != nil && o.redact.allowlist {
+		o.recordFrom(f, v, MASK, SourceAllowlist)
+	}
+}
+
+// currentPath returns the path of the value being rendered, or an empty
+// string if it is not tracked.
+func (o *options) currentPath() string {
+	if o.recorder == nil {
+		return ""
+	}
+	return strings.Join(o.recorder.path, "")
+}
@@ -0,0 +1,90 @@
+package render
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestAllowlist(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		ID       int `redact:"KEEP"`
+		Name     string
+		Email    string
+		Password string `redact:"REPLACE"`
+		Tags     map[string]string
+	}
+	type team struct {
+		Name    string `redact:"KEEP"`
+		Members []user
+		Lead    *user `redact:"KEEP"`
+	}
+	tm := team{
+		Name:    "core",
+		Members: []user{{ID: 1, Name: "alice", Email: "a@example.com", Password: "pw", Tags: map[string]string{"role": "admin"}}},
+		Lead:    &user{ID: 2, Name: "bob", Password: "pw"},
+	}
+
+	for i, tc := range []struct {
+		opts []MarshallerOption
+		s    string
+	}{
+		{[]MarshallerOption{WithAllowlist()}, `render.team{Name:"core", Members:[]render.user{render.user{ID:1, Name:"#####", Email:"#############", Password:<redacted>, Tags:map[string]string{"####":"#####"}}}, ` +
+			`Lead:(*render.user){ID:2, Name:"bob", Email:"", Password:<redacted>, Tags:map[string]string(nil)}}`},
+		{[]MarshallerOption{WithAllowlist(".Members[*].Name", ".Members[*].Tags")}, `render.team{Name:"core", Members:[]render.user{render.user{ID:1, Name:"alice", Email:"#############", Password:<redacted>, Tags:map[string]string{"role":"admin"}}}, ` +
+			`Lead:(*render.user){ID:2, Name:"bob", Email:"", Password:<redacted>, Tags:map[string]string(nil)}}`},
+		{[]MarshallerOption{WithAllowlist(".Members[0].*")}, `render.team{Name:"core", Members:[]render.user{render.user{ID:1, Name:"alice", Email:"a@example.com", Password:<redacted>, Tags:map[string]string{"role":"admin"}}}, ` +
+			`Lead:(*render.user){ID:2, Name:"bob", Email:"", Password:<redacted>, Tags:map[string]string(nil)}}`},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), tm, tc.s, tc.opts...)
+	}
+
+	// values outside structs are masked too
+	assertRedactsLike(t, "root", []interface{}{"secret", 1234}, `[]interface{}{"######", ####}`, WithAllowlist())
+
+	// bools are masked alike whatever their value
+	assertRedactsLike(t, "bools", []bool{true, false}, `[]bool{#####, #####}`, WithAllowlist())
+	assertRedactsLike(t, "masked bools", struct {
+		A bool `redact:"MASK"`
+		B bool `redact:"MASK(STRING)"`
+	}{true, false}, `struct { A bool "redact:\"MASK\""; B bool "redact:\"MASK(STRING)\"" }{#####, "#####"}`)
+
+	// formatted types are masked unless kept
+	type w struct{ s string }
+	formatter := WithTypeFormatter("render.w", func(v interface{}) string { return v.(w).s })
+	assertRedactsLike(t, "formatter", struct {
+		A w
+		B w `redact:"KEEP"`
+	}{w{"alice"}, w{"bob"}}, `struct { A render.w; B render.w "redact:\"KEEP\"" }{A:render.w(#####), B:render.w(bob)}`, WithAllowlist(), formatter)
+
+	m, err := NewMarshaller(WithAllowlist())
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+
+	// masked fields are reported
+	_, report := m.RedactWithReport(user{ID: 1, Name: "alice", Password: "pw"})
+	exp := []Redaction{
+		{Path: ".Name", Type: "string", Mode: MASK, Source: SourceAllowlist, Length: 5},
+		{Path: ".Email", Type: "string", Mode: MASK, Source: SourceAllowlist, Length: 0},
+		{Path: ".Password", Type: "string", Mode: REPLACE, Source: SourceTag, Length: 2},
+		{Path: ".Tags", Type: "map[string]string", Mode: MASK, Source: SourceAllowlist, Length: 22},
+	}
+	if !reflect.DeepEqual(report.Redactions, exp) {
+		t.Errorf("Report did not match expectations:\nExpected: %+v\nActual  : %+v\n", exp, report.Redactions)
+	}
+
+	copied, err := m.RedactValue(tm)
+	if err != nil {
+		t.Fatalf("RedactValue failed: %v", err)
+	}
+	if c := copied.(team); c.Name != "core" || c.Members[0].Name != "#####" || c.Members[0].ID != 1 || c.Lead.Name != "bob" {
+		t.Errorf("Unexpected copy: %s", Render(c))
+	}
+
+	if _, err := NewMarshaller(WithAllowlist("Members")); err == nil {
+		t.Errorf("Expected an error on an invalid path")
+	}
+}
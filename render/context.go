@@ -0,0 +1,94 @@
+package render
+
+import (
+	"context"
+	"reflect"
+	"strings"
+)
+
+type contextKey int
+
+const (
+	audienceContextKey contextKey = iota
+	optionsContextKey
+)
+
+// ContextWithAudience returns a copy of ctx carrying the audience RedactContext
+// redacts for. See RedactFor for more details on audiences.
+func ContextWithAudience(ctx context.Context, audience string) context.Context {
+	return context.WithValue(ctx, audienceContextKey, audience)
+}
+
+// ContextWithOptions returns a copy of ctx carrying options which
+// RedactContext applies on top of the marshaller ones, e.g. to change the
+// redact tag, the placeholders or the type formatters for a given request.
+// Options already carried by ctx are kept and applied first.
+func ContextWithOptions(ctx context.Context, opts ...MarshallerOption) context.Context {
+	prev, _ := ctx.Value(optionsContextKey).([]MarshallerOption)
+	all := make([]MarshallerOption, 0, len(prev)+len(opts))
+	all = append(append(all, prev...), opts...)
+	return context.WithValue(ctx, optionsContextKey, all)
+}
+
+// RedactContext redacts v like Redact, with the audience and options carried
+// by ctx (see ContextWithAudience and ContextWithOptions).
+//
+// If ctx is done before the traversal completes, RedactContext returns the
+// partial result along with the context error: every value which has not
+// been traversed is replaced by a "<canceled>" placeholder, so that the
+// result is still well-formed.
+//
+// Otherwise, the returned error is the one of the vault storing the tokens of
+// TOKENIZE fields, if any, the tokens it failed to store being replaced by the
+// replacement placeholder.
+func (m *Marshaller) RedactContext(ctx context.Context, v interface{}) (string, error) {
+	if opts, ok := ctx.Value(optionsContextKey).([]MarshallerOption); ok {
+		cm := m.clone()
+		for _, opt := range opts {
+			if opt != nil {
+				if err := opt(cm); err != nil {
+					return "", err
+				}
+			}
+		}
+		m = cm
+	}
+	opts := m.redactOptions()
+	if audience, ok := ctx.Value(audienceContextKey).(string); ok {
+		opts.redact.audience = audience
+	}
+	opts.ctx = ctx
+
+	str := strings.Builder{}
+	s := (*traverseState)(nil)
+	s.render(&str, 0, reflect.ValueOf(v), false, opts.redact.allowlist, opts)
+	redacted, err := opts.flushString(str.String())
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return redacted, err
+}
+
+// RedactContext redacts v with the audience and options carried by ctx. See
+// Marshaller.RedactContext for more details.
+func RedactContext(ctx context.Context, v interface{}) (string, error) {
+	m := newDefaultMarshaller()
+	return m.RedactContext(ctx, v)
+}
+
+// clone returns a copy of the marshaller which can be configured without
+// affecting the original one.
+func (m *Marshaller) clone() *Marshaller {
+	opts := *m.options
+	opts.render.typeFormatters = make(map[string]func(interface{}) string, len(m.options.render.typeFormatters))
+	for typeName, typeFormatter := range m.options.render.typeFormatters {
+		opts.render.typeFormatters[typeName] = typeFormatter
+	}
+	if m.options.redact.typePolicies != nil {
+		opts.redact.typePolicies = make(map[string]string, len(m.options.redact.typePolicies))
+		for typeName, policy := range m.options.redact.typePolicies {
+			opts.redact.typePolicies[typeName] = policy
+		}
+	}
+	return &Marshaller{options: &opts}
+}
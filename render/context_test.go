@@ -0,0 +1,82 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRedactContext(t *testing.T) {
+	t.Parallel()
+
+	type card struct {
+		Number string `redact:"support=MASK,default=REPLACE"`
+		Secret string `other:"REMOVE"`
+	}
+	v := card{Number: "4242424242424242", Secret: "s3cr3t"}
+
+	ctx := context.Background()
+	for i, tc := range []struct {
+		ctx context.Context
+		s   string
+	}{
+		{ctx, `render.card{Number:<redacted>, Secret:"s3cr3t"}`},
+		{ContextWithAudience(ctx, "support"), `render.card{Number:"####424242424242", Secret:"s3cr3t"}`},
+		{ContextWithOptions(ctx, WithRedactTag("other")), `render.card{Number:"4242424242424242"}`},
+		{ContextWithOptions(ContextWithOptions(ctx, WithRedactTag("other")), WithReplacementPlaceholder("hidden")),
+			`render.card{Number:"4242424242424242"}`},
+		{ContextWithOptions(ctx, WithReplacementPlaceholder("hidden")), `render.card{Number:<hidden>, Secret:"s3cr3t"}`},
+	} {
+		act, err := RedactContext(tc.ctx, v)
+		if err != nil {
+			t.Errorf("Input #%d: RedactContext failed: %v", i, err)
+		}
+		if act != tc.s {
+			t.Errorf("Input #%d did not match expectations:\nExpected: %s\nActual  : %s\n", i, tc.s, act)
+		}
+	}
+
+	// options carried by the context do not leak into the marshaller
+	m, err := NewMarshaller()
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+	if _, err := m.RedactContext(ContextWithOptions(ctx, WithReplacementPlaceholder("hidden")), v); err != nil {
+		t.Fatalf("RedactContext failed: %v", err)
+	}
+	if act, exp := m.Redact(v), `render.card{Number:<redacted>, Secret:"s3cr3t"}`; act != exp {
+		t.Errorf("Marshaller was modified:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+}
+
+type cancelingType int
+
+func TestRedactContextCanceled(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		List  []cancelingType
+		Map   map[string]int
+		After string
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m, err := NewMarshaller(WithTypeFormatter("render.cancelingType", func(v interface{}) string {
+		if v.(cancelingType) == 2 {
+			cancel()
+		}
+		return "ok"
+	}))
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+
+	act, err := m.RedactContext(ctx, testStruct{List: []cancelingType{1, 2, 3}, Map: map[string]int{"a": 1}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	exp := `render.testStruct{List:[]render.cancelingType{render.cancelingType(ok), render.cancelingType(ok), <canceled>}, <canceled>}`
+	if act != exp {
+		t.Errorf("Partial result did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+}
@@ -0,0 +1,266 @@
+package render
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RedactValue returns a redacted deep copy of v, as a value rather than a
+// string, e.g. to pass it to json.Marshal or to a third party logger.
+//
+// Struct fields are redacted based on their tags:
+//
+// - `redact:"REMOVE"` fields are zeroed
+//
+// - `redact:"REPLACE"` string and interface fields are set to the
+// "<redacted>" placeholder, other fields are zeroed
+//
+// - `redact:"HASH"` string and interface fields are set to the hash of their
+// value, other fields are zeroed
+//
+// - `redact:"TOKENIZE"` string and interface fields are set to a token, other
+// fields are zeroed
+//
+// - `redact:"ENCRYPT"` string and interface fields are set to a ciphertext,
+// other fields are zeroed
+//
+// - `redact:"MASK"` strings are masked. Numbers stored in interfaces are
+// replaced by their masked representation, other numbers are zeroed as a
+// masked number cannot be represented in their type.
+//
+// - `redact:"GENERALIZE"` strings only keep their generalization prefix in
+// clear, other values are treated like MASK ones
+//
+// - the members of slices, arrays and maps with element policies are redacted
+// like fields with the same tag, elided elements being replaced. Map keys
+// replaced by the same placeholder collapse into a single entry
+//
+// Pointers shared by several members of v, including cycles, are shared in
+// the same way in the copy.
+func (m *Marshaller) RedactValue(v interface{}) (copied interface{}, err error) {
+	src := reflect.ValueOf(v)
+	if !src.IsValid() {
+		return nil, nil
+	}
+	defer func() {
+		if panicError := recover(); panicError != nil {
+			copied, err = nil, fmt.Errorf("cannot copy %s: %v", src.Type(), panicError)
+		}
+	}()
+	c := &copier{
+		opts: m.redactOptions(),
+		seen: make(map[copyKey]reflect.Value),
+	}
+	dst := reflect.New(src.Type()).Elem()
+	c.copy(dst, addressable(src), c.opts.redact.allowlist, c.opts)
+	if err := c.opts.flush(); err != nil {
+		return nil, err
+	}
+	return dst.Interface(), nil
+}
+
+// RedactValue returns a redacted deep copy of v. See Marshaller.RedactValue
+// for more details.
+func RedactValue(v interface{}) (interface{}, error) {
+	m := newDefaultMarshaller()
+	return m.RedactValue(v)
+}
+
+// copyKey identifies a pointer, map or slice which has already been copied.
+type copyKey struct {
+	ptr uintptr
+	t   reflect.Type
+	len int
+}
+
+// copier holds the state of a single RedactValue call.
+type copier struct {
+	opts *options
+	seen map[copyKey]reflect.Value
+}
+
+// addressable returns v if it is addressable, or an addressable copy of v
+// otherwise, so that its unexported fields can be read with settable.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	a := reflect.New(v.Type()).Elem()
+	a.Set(v)
+	return a
+}
+
+// copy deep copies the addressable src to the settable dst.
+func (c *copier) copy(dst, src reflect.Value, mask bool, opts *options) {
+	t := src.Type()
+	switch t.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		key := copyKey{ptr: src.Pointer(), t: t}
+		if p, ok := c.seen[key]; ok {
+			dst.Set(p)
+			return
+		}
+		p := reflect.New(t.Elem())
+		c.seen[key] = p
+		c.copy(p.Elem(), src.Elem(), mask, opts)
+		dst.Set(p)
+
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		e := src.Elem()
+		if mask && isNumeric(e.Kind()) {
+			if masked := reflect.ValueOf(opts.maskNumberString(e)); masked.Type().AssignableTo(t) {
+				dst.Set(masked)
+			}
+			return
+		}
+		ne := reflect.New(e.Type()).Elem()
+		c.copy(ne, addressable(e), mask, opts)
+		dst.Set(ne)
+
+	case reflect.Struct:
+		opts := opts.withoutElementPolicies().forStruct(t)
+		for i := 0; i < t.NumField(); i++ {
+			sf, df := settable(src.Field(i)), settable(dst.Field(i))
+			switch tag, param, _, _ := opts.redactRule(t.Field(i)); tag {
+			case REMOVE:
+			case REPLACE, HASH, TOKENIZE, ENCRYPT:
+				c.replace(df, opts.placeholder(tag, sf))
+			case KEEP:
+				c.copy(df, sf, mask && !opts.kept(tag, ""), opts)
+			case MASK:
+				c.copy(df, sf, true, opts.withoutGeneralization())
+			case GENERALIZE:
+				c.copy(df, sf, true, opts.withGeneralization(param))
+			case elementsMode:
+				c.copy(df, sf, mask, opts.withElementPolicies(param))
+			default:
+				c.copy(df, sf, mask, opts)
+			}
+		}
+
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		key := copyKey{ptr: src.Pointer(), t: t, len: src.Len()}
+		if s, ok := c.seen[key]; ok {
+			dst.Set(s)
+			return
+		}
+		s := reflect.MakeSlice(t, src.Len(), src.Len())
+		c.seen[key] = s
+		elements := opts.redact.elements
+		opts = opts.withoutElementPolicies()
+		for i := 0; i < src.Len(); i++ {
+			c.copyElement(s.Index(i), src.Index(i), mask, elements.elemPolicy(i, src.Len()), opts)
+		}
+		dst.Set(s)
+
+	case reflect.Array:
+		elements := opts.redact.elements
+		opts = opts.withoutElementPolicies()
+		for i := 0; i < src.Len(); i++ {
+			c.copyElement(dst.Index(i), src.Index(i), mask, elements.elemPolicy(i, src.Len()), opts)
+		}
+
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		key := copyKey{ptr: src.Pointer(), t: t}
+		if m, ok := c.seen[key]; ok {
+			dst.Set(m)
+			return
+		}
+		m := reflect.MakeMapWithSize(t, src.Len())
+		c.seen[key] = m
+		elements := opts.redact.elements
+		opts = opts.withoutElementPolicies()
+		for _, k := range src.MapKeys() {
+			// map keys are not masked, as in traverseState.render, but
+			// redacted by their element policy
+			nk := reflect.New(t.Key()).Elem()
+			c.copyElement(nk, addressable(k), false, elements.keyPolicy(), opts)
+			nv := reflect.New(t.Elem()).Elem()
+			c.copyElement(nv, addressable(src.MapIndex(k)), mask, elements.valuePolicy(), opts)
+			m.SetMapIndex(nk, nv)
+		}
+		dst.Set(m)
+
+	case reflect.String:
+		if mask {
+			dst.SetString(opts.maskString(src.String()))
+		} else {
+			dst.Set(src)
+		}
+
+	default:
+		// masked numbers, channels and functions cannot be represented
+		if !mask {
+			dst.Set(src)
+		}
+	}
+}
+
+// copyElement deep copies the member src of a slice, array or map to dst
+// according to the mode of its element policy, see renderElement.
+func (c *copier) copyElement(dst, src reflect.Value, mask bool, policy string, opts *options) {
+	switch mode, param := splitModeParam(policy); mode {
+	case "":
+		c.copy(dst, src, mask, opts)
+	case KEEP:
+		c.copy(dst, src, mask && !opts.kept(mode, ""), opts)
+	case MASK:
+		c.copy(dst, src, true, opts.withoutGeneralization())
+	case GENERALIZE:
+		c.copy(dst, src, true, opts.withGeneralization(param))
+	case HASH, TOKENIZE, ENCRYPT:
+		c.replace(dst, opts.placeholder(mode, src))
+	default:
+		c.replace(dst, opts.placeholder(REPLACE, src))
+	}
+}
+
+// replace sets dst to placeholder if it can hold it.
+func (c *copier) replace(dst reflect.Value, placeholder string) {
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(placeholder)
+	case reflect.Interface:
+		if p := reflect.ValueOf(placeholder); p.Type().AssignableTo(dst.Type()) {
+			dst.Set(p)
+		}
+	}
+}
+
+func isNumeric(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
+
+func (o *options) maskString(value string) string {
+	str := strings.Builder{}
+	o.mask(&str, value)
+	return str.String()
+}
+
+// maskNumberString returns the masked representation of the number v, or its
+// generalized one when generalizing.
+func (o *options) maskNumberString(v reflect.Value) string {
+	if o.redact.generalizing {
+		return o.renderString(v, true)
+	}
+	return o.maskString(o.renderString(v, false))
+}
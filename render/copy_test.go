@@ -0,0 +1,95 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRedactValue(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Name     string
+		Password string        `redact:"REPLACE"`
+		Pin      int           `redact:"REPLACE"`
+		Any      interface{}   `redact:"REPLACE"`
+		Secret   string        `redact:"REMOVE"`
+		Token    string        `redact:"MASK"`
+		Masked   []interface{} `redact:"MASK"`
+		Count    int           `redact:"MASK"`
+		Tags     map[string]string
+		m        string `redact:"MASK"`
+		Next     *testStruct
+	}
+
+	src := &testStruct{
+		Name:     "foo",
+		Password: "hunter2",
+		Pin:      1234,
+		Any:      42,
+		Secret:   "s3cr3t",
+		Token:    "abcdefgh",
+		Masked:   []interface{}{123456, "abcdef"},
+		Count:    123456,
+		Tags:     map[string]string{"a": "b"},
+		m:        "unexported",
+	}
+	src.Next = src
+
+	copied, err := RedactValue(src)
+	if err != nil {
+		t.Fatalf("RedactValue failed: %v", err)
+	}
+	dst := copied.(*testStruct)
+	if dst == src || dst.Next != dst {
+		t.Errorf("Cycle was not preserved in the copy")
+	}
+	dst.Tags["a"] = "c"
+	if src.Tags["a"] != "b" {
+		t.Errorf("Map was not deep copied")
+	}
+	dst.Tags["a"] = "b"
+
+	exp := `(*render.testStruct){Name:"foo", Password:"<redacted>", Pin:0, Any:"<redacted>", Secret:"", ` +
+		`Token:"####efgh", Masked:[]interface{}{"####56", "####ef"}, Count:0, Tags:map[string]string{"a":"b"}, ` +
+		`m:"####ported", Next:<recursive(*render.testStruct)>}`
+	if act := Render(dst); act != exp {
+		t.Errorf("RedactValue did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+	if src.Password != "hunter2" || src.Token != "abcdefgh" {
+		t.Errorf("RedactValue modified its input")
+	}
+
+	type person struct {
+		Email string      `redact:"GENERALIZE(prefix=2)"`
+		Age   interface{} `redact:"GENERALIZE(bucket=10)"`
+	}
+	if copied, err := RedactValue(person{"joe@example.com", 34}); err != nil {
+		t.Errorf("RedactValue failed: %v", err)
+	} else if act, exp := Render(copied), `render.person{Email:"jo#############", Age:"30"}`; act != exp {
+		t.Errorf("RedactValue did not generalize:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	type vault struct {
+		Keys map[string]string `redact:"keys=REPLACE,values=REPLACE"`
+		Vals map[string]string `redact:"values=REPLACE"`
+		List []string          `redact:"first=1,last=1"`
+	}
+	v := vault{map[string]string{"alice@x.com": "s3cr3t"}, map[string]string{"apikey": "s3cr3t"}, []string{"a", "b", "c", "d"}}
+	if copied, err := RedactValue(v); err != nil {
+		t.Errorf("RedactValue failed: %v", err)
+	} else if act, exp := Render(copied), `render.vault{Keys:map[string]string{"<redacted>":"<redacted>"}, `+
+		`Vals:map[string]string{"apikey":"<redacted>"}, List:[]string{"a", "<redacted>", "<redacted>", "d"}}`; act != exp {
+		t.Errorf("RedactValue did not apply the element policies:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	for i, v := range []interface{}{nil, 1, "foo", []int(nil), [2]string{"a", "b"}, fmt.Stringer(nil)} {
+		copied, err := RedactValue(v)
+		if err != nil {
+			t.Errorf("Input #%d: RedactValue failed: %v", i, err)
+		}
+		if Render(copied) != Render(v) {
+			t.Errorf("Input #%d: expected %s, got %s", i, Render(v), Render(copied))
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package render
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Diff reports the differences between a and b, field path by field path.
+// See Marshaller.Diff for more details.
+func Diff(a, b interface{}) string {
+	m := newDefaultMarshaller()
+	return m.Diff(a, b)
+}
+
+// Diff walks a and b with the same rules as Redact and reports the paths of
+// the values that changed ("~"), were added in b ("+") or removed from b
+// ("-"), one per line. It returns an empty string if a and b render alike.
+//
+// Example:
+//
+//	~ .Name: "foo" -> "bar"
+//	+ .Tags["env"]: "prod"
+//	- .Items[2]: 3
+//
+// Fields tagged REMOVE, REPLACE, HASH, TOKENIZE or ENCRYPT are compared but
+// printed as the replacement placeholder, and fields tagged MASK or
+// GENERALIZE are printed masked or generalized. The members of slices, arrays
+// and maps with element policies, including elided elements, are printed the
+// same way.
+func (m *Marshaller) Diff(a, b interface{}) string {
+	d := &differ{
+		opts:  m.redactOptions(),
+		clear: m.options,
+	}
+	// tokens are never stored by Diff, so that TOKENIZE fields are printed
+	// as the replacement placeholder wherever they are nested
+	d.opts.tokens = nil
+	d.diff("", nil, nil, reflect.ValueOf(a), reflect.ValueOf(b), d.opts.redact.allowlist, d.opts)
+	return strings.Join(d.changes, "\n")
+}
+
+// differ accumulates the changes found while walking two values.
+type differ struct {
+	// opts are the options values are printed with, before the redaction
+	// rules of the fields holding them apply.
+	opts *options
+	// clear are used to compare values.
+	clear   *options
+	changes []string
+}
+
+func (d *differ) changed(path string, a, b reflect.Value, mask bool, opts *options) {
+	d.changes = append(d.changes, "~ "+displayPath(path)+": "+opts.renderString(a, mask)+" -> "+opts.renderString(b, mask))
+}
+
+func (d *differ) added(path string, b reflect.Value, mask bool, policy string, opts *options) {
+	d.changes = append(d.changes, "+ "+displayPath(path)+": "+d.print(b, mask, policy, opts))
+}
+
+func (d *differ) removed(path string, a reflect.Value, mask bool, policy string, opts *options) {
+	d.changes = append(d.changes, "- "+displayPath(path)+": "+d.print(a, mask, policy, opts))
+}
+
+func (d *differ) redacted(path string) {
+	d.changes = append(d.changes, "~ "+displayPath(path)+": "+d.placeholder()+" -> "+d.placeholder())
+}
+
+func (d *differ) placeholder() string {
+	return "<" + d.opts.redact.replacementPlaceholder + ">"
+}
+
+// print renders v with the mode of its element policy, if it is a member of
+// a slice, array or map, as renderElement does, but for the placeholder
+// modes which are printed as the replacement placeholder.
+func (d *differ) print(v reflect.Value, mask bool, policy string, opts *options) string {
+	switch mode, _ := splitModeParam(policy); mode {
+	case "", KEEP, MASK, GENERALIZE:
+		str := strings.Builder{}
+		(*traverseState)(nil).renderElement(&str, v, false, mask, policy, opts)
+		return str.String()
+	}
+	return d.placeholder()
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+// equal returns true if a and b render alike without any redaction.
+func (d *differ) equal(a, b reflect.Value) bool {
+	return d.clear.renderString(a, false) == d.clear.renderString(b, false)
+}
+
+func (d *differ) diff(path string, sa, sb *traverseState, a, b reflect.Value, mask bool, opts *options) {
+	if !a.IsValid() || !b.IsValid() {
+		if a.IsValid() != b.IsValid() {
+			d.changed(path, a, b, mask, opts)
+		}
+		return
+	}
+	vt := a.Type()
+	if vt != b.Type() {
+		d.changed(path, a, b, mask, opts)
+		return
+	}
+	if _, ok := opts.render.typeFormatters[vt.String()]; ok {
+		if !d.equal(a, b) {
+			d.changed(path, a, b, mask, opts)
+		}
+		return
+	}
+	// errors are compared and printed by their scrubbed text, see
+	// WithErrorRendering
+	if opts.isError(a) || opts.isError(b) {
+		if !d.equal(a, b) {
+			d.changed(path, a, b, mask, opts)
+		}
+		return
+	}
+
+	// Avoid recursion the same way traverseState.render does: stop as soon as
+	// either side loops back on itself.
+	pa, pb := uintptr(0), uintptr(0)
+	switch vt.Kind() {
+	case reflect.Ptr:
+		if a.IsNil() || b.IsNil() {
+			break
+		}
+		switch vt.Elem().Kind() {
+		case reflect.Struct, reflect.Array:
+			pa, pb = a.Pointer(), b.Pointer()
+		}
+	case reflect.Slice, reflect.Map:
+		pa, pb = a.Pointer(), b.Pointer()
+	}
+	if pa != 0 && pb != 0 {
+		sa, sb = sa.forkFor(pa), sb.forkFor(pb)
+		if sa == nil || sb == nil {
+			if (sa == nil) != (sb == nil) {
+				d.changed(path, a, b, mask, opts)
+			}
+			return
+		}
+	}
+
+	switch vt.Kind() {
+	case reflect.Struct:
+		opts := opts.withoutElementPolicies().forStruct(vt)
+		for i := 0; i < vt.NumField(); i++ {
+			f := vt.Field(i)
+			fieldPath := path + "." + opts.fieldName(f)
+			af, bf := a.Field(i), b.Field(i)
+			tag, param, _, _ := opts.redactRule(f)
+			mask, fieldOpts := mask && !opts.kept(tag, fieldPath), opts
+			switch tag {
+			case elementsMode:
+				fieldOpts = opts.withElementPolicies(param)
+			case GENERALIZE:
+				mask, fieldOpts = true, opts.withGeneralization(param)
+			case MASK:
+				mask, fieldOpts = true, opts.withoutGeneralization()
+				if param != "" {
+					fieldOpts = fieldOpts.withNumberMasking(param)
+				}
+			}
+			// fields omitted by Redact are skipped, but REMOVE ones which
+			// are compared like REPLACE ones
+			oa := tag != REMOVE && opts.isOmitted(f, af)
+			ob := tag != REMOVE && opts.isOmitted(f, bf)
+			switch {
+			case oa && ob:
+			case tag == REMOVE || isPlaceholderMode(tag):
+				if !d.equal(af, bf) {
+					d.redacted(fieldPath)
+				}
+			case oa:
+				d.added(fieldPath, bf, mask, "", fieldOpts)
+			case ob:
+				d.removed(fieldPath, af, mask, "", fieldOpts)
+			default:
+				d.diff(fieldPath, sa, sb, af, bf, mask, fieldOpts)
+			}
+		}
+
+	case reflect.Slice:
+		if a.IsNil() != b.IsNil() {
+			d.changed(path, a, b, mask, opts)
+			return
+		}
+		fallthrough
+
+	case reflect.Array:
+		elements := opts.redact.elements
+		opts := opts.withoutElementPolicies()
+		policy := elements.valuePolicy()
+		for i := 0; i < a.Len() || i < b.Len(); i++ {
+			elemPath := path + "[" + strconv.Itoa(i) + "]"
+			elidedA, _ := elements.elided(i, a.Len())
+			elidedB, _ := elements.elided(i, b.Len())
+			switch {
+			case elidedA || elidedB:
+				// elided elements are compared but never printed
+				if i >= a.Len() || i >= b.Len() || !d.equal(a.Index(i), b.Index(i)) {
+					d.redacted(elemPath)
+				}
+			case i >= b.Len():
+				d.removed(elemPath, a.Index(i), mask, policy, opts)
+			case i >= a.Len():
+				d.added(elemPath, b.Index(i), mask, policy, opts)
+			default:
+				d.diffElement(elemPath, sa, sb, a.Index(i), b.Index(i), mask, policy, opts)
+			}
+		}
+
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() {
+			d.changed(path, a, b, mask, opts)
+			return
+		}
+		keys := a.MapKeys()
+		for _, k := range b.MapKeys() {
+			if !a.MapIndex(k).IsValid() {
+				keys = append(keys, k)
+			}
+		}
+		tryAndSortMapKeys(vt, keys, opts)
+		elements := opts.redact.elements
+		opts := opts.withoutElementPolicies()
+		keyMode, policy := "", elements.valuePolicy()
+		if mask {
+			keyMode = opts.redact.mapKeys
+		}
+		if elements.keyPolicy() != "" {
+			keyMode = elements.keyPolicy()
+		}
+		for _, k := range keys {
+			elemPath := path + "[" + opts.renderString(k, false) + "]"
+			if keyMode != "" && keyMode != KEEP {
+				elemPath = path + "[" + sa.redactKey(k, false, keyMode, opts) + "]"
+			}
+			av, bv := a.MapIndex(k), b.MapIndex(k)
+			switch {
+			case !bv.IsValid():
+				d.removed(elemPath, av, mask, policy, opts)
+			case !av.IsValid():
+				d.added(elemPath, bv, mask, policy, opts)
+			default:
+				d.diffElement(elemPath, sa, sb, av, bv, mask, policy, opts)
+			}
+		}
+
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				d.changed(path, a, b, mask, opts)
+			}
+			return
+		}
+		d.diff(path, sa, sb, a.Elem(), b.Elem(), mask, opts)
+
+	default:
+		if !d.equal(a, b) {
+			d.changed(path, a, b, mask, opts)
+		}
+	}
+}
+
+// diffElement diffs the members a and b of a slice, array or map according
+// to the mode of their element policy, see renderElement.
+func (d *differ) diffElement(path string, sa, sb *traverseState, a, b reflect.Value, mask bool, policy string, opts *options) {
+	switch mode, param := splitModeParam(policy); mode {
+	case "":
+		d.diff(path, sa, sb, a, b, mask, opts)
+	case KEEP:
+		d.diff(path, sa, sb, a, b, mask && !opts.kept(mode, ""), opts)
+	case MASK:
+		opts = opts.withoutGeneralization()
+		if param != "" {
+			opts = opts.withNumberMasking(param)
+		}
+		d.diff(path, sa, sb, a, b, true, opts)
+	case GENERALIZE:
+		d.diff(path, sa, sb, a, b, true, opts.withGeneralization(param))
+	default:
+		if !d.equal(a, b) {
+			d.redacted(path)
+		}
+	}
+}
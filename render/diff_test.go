@@ -0,0 +1,120 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Name     string
+		Password string `redact:"REPLACE"`
+		Token    string `redact:"MASK"`
+		Tags     map[string]string
+		Items    []int
+		I        interface{}
+		Next     *testStruct
+		Secret   string `redact:"REMOVE"`
+	}
+
+	base := func() *testStruct {
+		return &testStruct{
+			Name:     "foo",
+			Password: "hunter2",
+			Token:    "abcdefgh",
+			Secret:   "s3cr3t",
+			Tags:     map[string]string{"a": "1", "b": "2"},
+			Items:    []int{1, 2, 3},
+			I:        42,
+		}
+	}
+
+	recursive := base()
+	recursive.Next = recursive
+
+	for i, tc := range []struct {
+		a, b interface{}
+		s    string
+	}{
+		{base(), base(), ``},
+		{recursive, recursive, ``},
+		{1, 2, `~ .: 1 -> 2`},
+		{nil, 2, `~ .: nil -> 2`},
+		{1, "1", `~ .: 1 -> "1"`},
+		{base(), func() *testStruct {
+			b := base()
+			b.Name = "bar"
+			b.Password = "hunter3"
+			b.Token = "abcdefgX"
+			b.Secret = "other"
+			return b
+		}(), "~ .Name: \"foo\" -> \"bar\"\n" +
+			"~ .Password: <redacted> -> <redacted>\n" +
+			"~ .Token: \"####efgh\" -> \"####efgX\"\n" +
+			"~ .Secret: <redacted> -> <redacted>"},
+		{base(), func() *testStruct {
+			b := base()
+			delete(b.Tags, "a")
+			b.Tags["c"] = "3"
+			b.Items = b.Items[:2]
+			b.I = "42"
+			b.Next = &testStruct{}
+			return b
+		}(), "- .Tags[\"a\"]: \"1\"\n" +
+			"+ .Tags[\"c\"]: \"3\"\n" +
+			"- .Items[2]: 3\n" +
+			"~ .I: 42 -> \"42\"\n" +
+			"~ .Next: (*render.testStruct)(nil) -> (*render.testStruct){Name:\"\", Password:<redacted>, Token:\"\", Tags:map[string]string(nil), Items:[]int(nil), I:interface{}(nil), Next:(*render.testStruct)(nil)}"},
+	} {
+		if act := Diff(tc.a, tc.b); act != tc.s {
+			t.Errorf("Input #%d did not match expectations:\nExpected: %s\nActual  : %s\n", i, tc.s, act)
+		}
+	}
+}
+
+func TestDiffRedactRules(t *testing.T) {
+	t.Parallel()
+
+	type account struct {
+		PIN int `redact:"MASK(ZERO)"`
+		Age int `redact:"MASK(ROUND=10)"`
+	}
+	type person struct {
+		Email string `redact:"GENERALIZE(prefix=2)"`
+		Age   int    `redact:"GENERALIZE(bucket=10)"`
+	}
+	type vault struct {
+		Keys map[string]string `redact:"keys=REPLACE,values=REPLACE"`
+		Vals map[string]string `redact:"values=REPLACE"`
+		List []string          `redact:"first=1,last=1"`
+	}
+
+	for i, tc := range []struct {
+		a, b interface{}
+		s    string
+	}{
+		{account{123456, 34}, account{654321, 47}, "~ .PIN: 0 -> 0\n~ .Age: 30 -> 40"},
+		{person{"joe@example.com", 34}, person{"jim@example.com", 47}, "~ .Email: \"jo#############\" -> \"ji#############\"\n~ .Age: 30 -> 40"},
+		{vault{map[string]string{"alice@x.com": "rsecretvalue"}, map[string]string{"apikey": "rsecretvalue"}, []string{"a", "b", "c", "d"}},
+			vault{map[string]string{"alice@x.com": "rsecretvalu2"}, map[string]string{"apikey": "rsecretvalu2"}, []string{"a", "B", "c", "D"}},
+			"~ .Keys[<redacted>]: <redacted> -> <redacted>\n~ .Vals[\"apikey\"]: <redacted> -> <redacted>\n" +
+				"~ .List[1]: <redacted> -> <redacted>\n~ .List[3]: \"d\" -> \"D\""},
+	} {
+		if act := Diff(tc.a, tc.b); act != tc.s {
+			t.Errorf("Input #%d did not match expectations:\nExpected: %s\nActual  : %s\n", i, tc.s, act)
+		}
+	}
+}
+
+func ExampleDiff() {
+	type user struct {
+		Name     string
+		Password string `redact:"REPLACE"`
+	}
+	fmt.Println(Diff(user{"alice", "foo"}, user{"bob", "bar"}))
+	// Output:
+	// ~ .Name: "alice" -> "bob"
+	// ~ .Password: <redacted> -> <redacted>
+}
@@ -0,0 +1,151 @@
+package render
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// elementsMode is the mode of the fields whose tag holds element policies,
+// such as `redact:"keys=KEEP,values=MASK"`.
+const elementsMode = "ELEMENTS"
+
+// elementPolicies holds the policies applying to the members of a slice,
+// array or map field.
+type elementPolicies struct {
+	// keys is the mode of map keys.
+	keys string
+	// values is the mode of map values and of slice and array elements.
+	values string
+	// first and last are the numbers of leading and trailing elements of
+	// slices and arrays which are rendered, the others being elided. Both
+	// being 0 renders all elements.
+	first, last int
+}
+
+// isElementPolicies returns true if tag is a list of element policies rather
+// than of audience policies.
+func isElementPolicies(tag string) bool {
+	for _, policy := range strings.Split(tag, ",") {
+		switch target, _, _ := strings.Cut(strings.TrimSpace(policy), "="); target {
+		case "keys", "values", "elems", "first", "last":
+			return true
+		}
+	}
+	return false
+}
+
+// parseElementPolicies parses a list of element policies. Invalid counts are
+// ignored, and unknown targets, which are likely misspelled, make both keys
+// and values replaced.
+func parseElementPolicies(tag string) *elementPolicies {
+	p := &elementPolicies{}
+	unknown := false
+	for _, policy := range strings.Split(tag, ",") {
+		target, value, _ := strings.Cut(strings.TrimSpace(policy), "=")
+		switch target {
+		case "keys":
+			p.keys = value
+		case "values", "elems":
+			p.values = value
+		case "first":
+			p.first, _ = strconv.Atoi(value)
+		case "last":
+			p.last, _ = strconv.Atoi(value)
+		default:
+			unknown = true
+		}
+	}
+	if unknown {
+		p.keys, p.values = REPLACE, REPLACE
+	}
+	if p.first < 0 {
+		p.first = 0
+	}
+	if p.last < 0 {
+		p.last = 0
+	}
+	return p
+}
+
+// withElementPolicies returns a copy of the options applying the element
+// policies of tag to the next slice, array or map rendered.
+func (o *options) withElementPolicies(tag string) *options {
+	opts := *o
+	opts.redact.elements = parseElementPolicies(tag)
+	return &opts
+}
+
+// withoutElementPolicies returns a copy of the options without element
+// policies, or the options themselves if they have none.
+func (o *options) withoutElementPolicies() *options {
+	if o.redact.elements == nil {
+		return o
+	}
+	opts := *o
+	opts.redact.elements = nil
+	return &opts
+}
+
+// elided returns true if the element i of a slice or array of length n is
+// not rendered, along with the number of consecutive elided elements.
+func (p *elementPolicies) elided(i, n int) (bool, int) {
+	if p == nil || p.first+p.last == 0 || n <= p.first+p.last {
+		return false, 0
+	}
+	if i < p.first || i >= n-p.last {
+		return false, 0
+	}
+	return true, n - p.last - p.first
+}
+
+// keyPolicy returns the policy of map keys, or "" without element policies.
+func (p *elementPolicies) keyPolicy() string {
+	if p == nil {
+		return ""
+	}
+	return p.keys
+}
+
+// valuePolicy returns the policy of map values, or "" without element
+// policies.
+func (p *elementPolicies) valuePolicy() string {
+	if p == nil {
+		return ""
+	}
+	return p.values
+}
+
+// elemPolicy returns the policy of the element i of a slice or array of
+// length n, REPLACE if it is elided.
+func (p *elementPolicies) elemPolicy(i, n int) string {
+	if elided, _ := p.elided(i, n); elided {
+		return REPLACE
+	}
+	return p.valuePolicy()
+}
+
+// renderElement renders the member v of a slice, array or map according to
+// the mode of its element policy. Members cannot be removed, so REMOVE and
+// unknown modes replace them.
+func (s *traverseState) renderElement(str *strings.Builder, v reflect.Value, anon bool, mask bool, policy string, opts *options) {
+	mode, param := splitModeParam(policy)
+	switch {
+	case isPlaceholderMode(mode):
+		str.WriteString(opts.placeholder(mode, v))
+	case mode == MASK:
+		opts = opts.withoutGeneralization()
+		if param != "" {
+			opts = opts.withNumberMasking(param)
+		}
+		s.render(str, 0, v, anon, true, opts)
+	case mode == GENERALIZE:
+		s.render(str, 0, v, anon, true, opts.withGeneralization(param))
+	case mode == KEEP:
+		s.render(str, 0, v, anon, mask && !opts.kept(mode, ""), opts)
+	case mode == "":
+		s.render(str, 0, v, anon, mask, opts)
+	default:
+		str.WriteString(opts.placeholder(REPLACE, v))
+	}
+}
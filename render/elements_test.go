@@ -0,0 +1,78 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestElementPolicies(t *testing.T) {
+	t.Parallel()
+
+	type vaultState struct {
+		Tokens  []string            `redact:"elems=MASK"`
+		Secrets map[string]string   `redact:"keys=KEEP,values=REPLACE"`
+		Emails  map[string]int      `redact:"keys=HASH"`
+		Scores  map[string]int      `redact:"values=MASK(ZERO)"`
+		IDs     []int               `redact:"first=2,last=1"`
+		Hashes  [4]string           `redact:"elems=REPLACE,first=1"`
+		Short   []int               `redact:"first=2,last=2"`
+		Nested  map[string][]string `redact:"values=MASK"`
+	}
+	v := vaultState{
+		Tokens:  []string{"abcdef", "ghijkl"},
+		Secrets: map[string]string{"db": "hunter2", "api": "s3cr3t"},
+		Emails:  map[string]int{"bob@example.com": 1},
+		Scores:  map[string]int{"bob": 87},
+		IDs:     []int{1, 2, 3, 4, 5, 6},
+		Hashes:  [4]string{"a", "b", "c", "d"},
+		Short:   []int{1, 2, 3},
+		Nested:  map[string][]string{"bob": {"secret"}},
+	}
+
+	for i, tc := range []struct {
+		a interface{}
+		s string
+	}{
+		{v, `render.vaultState{Tokens:[]string{"####ef", "####kl"}, Secrets:map[string]string{"api":<redacted>, "db":<redacted>}, ` +
+			`Emails:map[string]int{<hmac:b70c3ba10e84fbd2>:1}, Scores:map[string]int{"bob":0}, IDs:[]int{1, 2, <3 elided>, 6}, ` +
+			`Hashes:[4]string{<redacted>, <3 elided>}, Short:[]int{1, 2, 3}, Nested:map[string][]string{"bob":{"####et"}}}`},
+		{struct {
+			M map[string]string `redact:"keys=KEEP,values=KEEP"`
+		}{map[string]string{"a": "b"}}, `struct { M map[string]string "redact:\"keys=KEEP,values=KEEP\"" }{{"a":"b"}}`},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), tc.a, tc.s, WithHashKey(testHashKey))
+	}
+
+	// element policies are rendered like any field with Render
+	if act, exp := Render(v.IDs), `[]int{1, 2, 3, 4, 5, 6}`; act != exp {
+		t.Errorf("Render did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	// REMOVE and unknown modes or targets fail closed
+	for i, tc := range []struct {
+		a interface{}
+		s string
+	}{
+		{struct {
+			Tokens []string `redact:"values=REMOVE"`
+		}{[]string{"secret1"}}, `struct { Tokens []string "redact:\"values=REMOVE\"" }{{<redacted>}}`},
+		{struct {
+			M map[string]int `redact:"keys=REMOVE"`
+		}{map[string]int{"bob@example.com": 1}}, `struct { M map[string]int "redact:\"keys=REMOVE\"" }{{<redacted>:1}}`},
+		{struct {
+			Tokens []string `redact:"values=MASKK"`
+		}{[]string{"secret1"}}, `struct { Tokens []string "redact:\"values=MASKK\"" }{{<redacted>}}`},
+		{struct {
+			M map[string]string `redact:"keys=KEEP,value=MASK"`
+		}{map[string]string{"bob": "secret1"}}, `struct { M map[string]string "redact:\"keys=KEEP,value=MASK\"" }{{<redacted>:<redacted>}}`},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Fail closed #%d", i), tc.a, tc.s)
+	}
+
+	_, report := RedactWithReport(struct {
+		M map[string]string `redact:"keys=HASH,values=MASK"`
+	}{map[string]string{"bob@example.com": "x"}})
+	if len(report.Redactions) != 1 || report.Redactions[0].Mode != "keys=HASH,values=MASK" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
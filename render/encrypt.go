@@ -0,0 +1,145 @@
+package render
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"reflect"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// KeyProvider provides the AES keys of the ENCRYPT redact mode. Keys are
+// identified by an ID written along the ciphertext, so that keys can be
+// rotated while older outputs can still be decrypted.
+//
+// Implementations must be safe for concurrent use.
+type KeyProvider interface {
+	// CurrentKey returns the key to encrypt with, and its ID. The key must be
+	// 16, 24 or 32 bytes long, and the ID must validate ^[a-zA-Z0-9_-]+$.
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key of the given ID, to decrypt with.
+	Key(id string) ([]byte, error)
+}
+
+// WithKeyProvider lets you set the provider of the keys used when the
+// redacting mode is set to "ENCRYPT". Values are then rendered as
+// "<enc:keyID:ciphertext>", the ciphertext being the base64 of the AES-GCM
+// encryption of their representation, which Decrypt reverts.
+//
+// Without key provider, or if encryption fails, ENCRYPT fields are replaced
+// like REPLACE ones.
+func WithKeyProvider(keys KeyProvider) MarshallerOption {
+	return func(m *Marshaller) error {
+		m.options.redact.keys = keys
+		return nil
+	}
+}
+
+// StaticKey returns a KeyProvider holding a single key.
+func StaticKey(id string, key []byte) KeyProvider {
+	return staticKey{id: id, key: key}
+}
+
+type staticKey struct {
+	id  string
+	key []byte
+}
+
+func (k staticKey) CurrentKey() (string, []byte, error) {
+	return k.id, k.key, nil
+}
+
+func (k staticKey) Key(id string) ([]byte, error) {
+	if id != k.id {
+		return nil, errors.Errorf("unknown key %s", id)
+	}
+	return k.key, nil
+}
+
+var encryptedRegex = regexp.MustCompile(`<enc:([a-zA-Z0-9_-]+):([A-Za-z0-9+/]+={0,2})>`)
+
+// encrypt returns the ciphertext replacing v, or the replacement placeholder
+// if v cannot be encrypted.
+func (o *options) encrypt(v reflect.Value) string {
+	id, key, err := o.redact.keys.CurrentKey()
+	if err != nil || !tagRegex.MatchString(id) {
+		return "<" + o.redact.replacementPlaceholder + ">"
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "<" + o.redact.replacementPlaceholder + ">"
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "<" + o.redact.replacementPlaceholder + ">"
+	}
+	plain := []byte(o.clear().renderString(v, false))
+	sealed := aead.Seal(nonce, nonce, plain, []byte(id))
+	return "<enc:" + id + ":" + base64.StdEncoding.EncodeToString(sealed) + ">"
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Decrypt replaces the ciphertexts of s, as written by the ENCRYPT redact
+// mode, by the values they stand for, rendered as by Render.
+//
+// An error is returned if a ciphertext cannot be decrypted with the keys
+// given by keys.
+func Decrypt(s string, keys KeyProvider) (string, error) {
+	var err error
+	decrypted := encryptedRegex.ReplaceAllStringFunc(s, func(match string) string {
+		if err != nil {
+			return match
+		}
+		var plain string
+		plain, err = decrypt(encryptedRegex.FindStringSubmatch(match), keys)
+		return plain
+	})
+	if err != nil {
+		return "", err
+	}
+	return decrypted, nil
+}
+
+// Decrypt replaces the ciphertexts of s by the values they stand for, using
+// the keys set with WithKeyProvider. See Decrypt for more details.
+func (m *Marshaller) Decrypt(s string) (string, error) {
+	if m.options.redact.keys == nil {
+		return "", errors.New("no key provider set")
+	}
+	return Decrypt(s, m.options.redact.keys)
+}
+
+// decrypt decrypts a ciphertext matched by encryptedRegex.
+func decrypt(match []string, keys KeyProvider) (string, error) {
+	id, encoded := match[1], match[2]
+	key, err := keys.Key(id)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot get key %s", id)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid key %s", id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid ciphertext")
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("invalid ciphertext: too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot decrypt with key %s", id)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,84 @@
+package render
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type rotatingKeys map[string][]byte
+
+func (k rotatingKeys) CurrentKey() (string, []byte, error) {
+	return "v2", k["v2"], nil
+}
+
+func (k rotatingKeys) Key(id string) ([]byte, error) {
+	return k[id], nil
+}
+
+func TestEncrypt(t *testing.T) {
+	t.Parallel()
+
+	type card struct {
+		Number string            `redact:"ENCRYPT"`
+		CVV    int               `redact:"ENCRYPT"`
+		Meta   map[string]string `redact:"ENCRYPT"`
+	}
+	c := card{"4111111111111111", 123, map[string]string{"bank": "foo"}}
+
+	keys := StaticKey("k1", []byte("0123456789abcdef"))
+	m, err := NewMarshaller(WithKeyProvider(keys))
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+	redacted := m.Redact(c)
+	if !regexp.MustCompile(`^render\.card\{Number:<enc:k1:[A-Za-z0-9+/=]+>, CVV:<enc:k1:[A-Za-z0-9+/=]+>, Meta:<enc:k1:[A-Za-z0-9+/=]+>\}$`).MatchString(redacted) {
+		t.Fatalf("Unexpected redaction: %s", redacted)
+	}
+	if strings.Contains(redacted, "4111") {
+		t.Errorf("Value leaked: %s", redacted)
+	}
+
+	act, err := m.Decrypt(redacted)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if exp := Render(c); act != exp {
+		t.Errorf("Decrypt did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	if _, err := Decrypt(redacted, StaticKey("k1", []byte("fedcba9876543210"))); err == nil {
+		t.Errorf("Expected an error when decrypting with the wrong key")
+	}
+	if _, err := Decrypt(redacted, StaticKey("k2", []byte("0123456789abcdef"))); err == nil {
+		t.Errorf("Expected an error when decrypting with an unknown key")
+	}
+	tampered := strings.Replace(redacted, "<enc:k1:", "<enc:k1:AAAA", 1)
+	if _, err := Decrypt(tampered, keys); err == nil {
+		t.Errorf("Expected an error when decrypting a tampered ciphertext")
+	}
+
+	// keys can be rotated, older ciphertexts remaining readable
+	rotating := rotatingKeys{"v1": []byte("0123456789abcdef"), "v2": []byte("0123456789abcdef01234567")}
+	old := strings.ReplaceAll(redacted, "<enc:k1:", "<enc:v1:")
+	m, err = NewMarshaller(WithKeyProvider(rotating))
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+	redacted = m.Redact(c)
+	if !strings.Contains(redacted, "<enc:v2:") {
+		t.Errorf("Expected the current key to be used: %s", redacted)
+	}
+	if act, err := m.Decrypt(redacted); err != nil || act != Render(c) {
+		t.Errorf("Decrypt returned (%s, %v)", act, err)
+	}
+	if _, err := m.Decrypt(old); err == nil {
+		t.Errorf("Expected an error as the key ID is authenticated")
+	}
+
+	// without key, or with an invalid one, ENCRYPT behaves like REPLACE
+	exp := `render.card{Number:<redacted>, CVV:<redacted>, Meta:<redacted>}`
+	assertRedactsLike(t, "without key", c, exp)
+	assertRedactsLike(t, "invalid key", c, exp, WithKeyProvider(StaticKey("k1", []byte("short"))))
+	assertRedactsLike(t, "invalid key ID", c, exp, WithKeyProvider(StaticKey("k:1", []byte("0123456789abcdef"))))
+}
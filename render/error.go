@@ -0,0 +1,280 @@
+package render
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// maxErrorLayers bounds the walk of error chains when scrubbing error texts.
+const maxErrorLayers = 64
+
+// WithErrorRendering lets you render the values implementing error by their
+// Error() text rather than by their internal structure, along with the
+// errors they wrap, e.g.:
+//
+//	(*fmt.wrapError)("load user: not found", (*errors.errorString)("not found"))
+//
+// Errors whose type is a struct with exported fields are rendered with these
+// fields instead of the errors they wrap, so that redact tags apply to them:
+//
+//	(*app.UserError)("bad user <redacted>"){Email:<redacted>, Err:error(nil)}
+//
+// When redacting, the values of the tagged fields of the errors of a chain,
+// including the fields of their nested structs, are scrubbed from the Error()
+// texts of the whole chain, as are the matches of the scrubbers set with
+// WithErrorScrubber. Strings and numbers are scrubbed as formatted by %v, so
+// that a short number also scrubs its other occurrences in the texts, while
+// booleans are not scrubbed.
+//
+// Errors held by unexported fields are rendered the same way, unless they are
+// values which can be neither addressed nor dereferenced, such as non-pointer
+// errors held by the values of a map.
+func WithErrorRendering() MarshallerOption {
+	return func(m *Marshaller) error {
+		m.options.render.errors = true
+		return nil
+	}
+}
+
+// WithErrorScrubber lets you set a function scrubbing the Error() texts of
+// rendered errors when redacting, e.g. to remove emails or card numbers found
+// by a detector. Scrubbers are called in the order they were set. See
+// WithErrorRendering.
+func WithErrorScrubber(scrubber func(string) string) MarshallerOption {
+	return func(m *Marshaller) error {
+		scrubbers := m.options.redact.errorScrubbers
+		m.options.redact.errorScrubbers = append(scrubbers[:len(scrubbers):len(scrubbers)], scrubber)
+		return nil
+	}
+}
+
+// isError returns true if v is a non-nil error value which must be rendered
+// as such.
+func (o *options) isError(v reflect.Value) bool {
+	if !o.render.errors || v.Kind() == reflect.Interface || !v.Type().Implements(errorType) {
+		return false
+	}
+	if _, ok := interfaceable(v); !ok {
+		return false
+	}
+	return !isNil(v)
+}
+
+// interfaceable returns v, or the same value read with settable or through
+// its pointer if v was reached through unexported fields, so that its methods
+// can be called. It returns false if v cannot be read that way, i.e. for
+// values which are neither addressable nor pointers.
+func interfaceable(v reflect.Value) (reflect.Value, bool) {
+	switch {
+	case v.CanInterface():
+		return v, true
+	case v.CanAddr():
+		return settable(v), true
+	case v.Kind() == reflect.Ptr && !v.IsNil():
+		return reflect.NewAt(v.Type().Elem(), unsafe.Pointer(v.Pointer())).Convert(v.Type()), true
+	}
+	return v, false
+}
+
+// renderError renders the error v by its text, followed by either its
+// exported fields or the errors it wraps. It returns false if the error
+// could not be rendered, e.g. because its Error method panicked.
+func (s *traverseState) renderError(str *strings.Builder, ptrs int, v reflect.Value, implicit bool, mask bool, opts *options) (rendered bool) {
+	v, _ = interfaceable(v)
+	err := v.Interface().(error)
+	text, ok := errorText(err)
+	if !ok {
+		return false
+	}
+	if opts.redact.active {
+		text = opts.scrubErrorText(err, text)
+	}
+	if mask {
+		text = opts.maskString(text)
+	}
+
+	if !implicit {
+		if v.Kind() == reflect.Ptr {
+			writeType(str, ptrs+1, v.Type().Elem())
+		} else {
+			writeType(str, ptrs, v.Type())
+		}
+	}
+	str.WriteRune('(')
+	str.WriteString(strconv.Quote(text))
+
+	if sv, ok := errorStruct(v); ok && hasExportedFields(sv.Type()) {
+		str.WriteString("){")
+		written := false
+		s.renderFields(str, sv, mask, "", &written, nil, opts.withoutElementPolicies())
+		str.WriteRune('}')
+		return true
+	}
+	for _, wrapped := range unwrap(err) {
+		str.WriteString(", ")
+		s.render(str, 0, reflect.ValueOf(wrapped), false, mask, opts)
+	}
+	str.WriteRune(')')
+	return true
+}
+
+// errorText returns the Error() text of err, and false if Error panicked.
+func errorText(err error) (text string, ok bool) {
+	defer func() {
+		if panicError := recover(); panicError != nil {
+			ok = false
+		}
+	}()
+	return err.Error(), true
+}
+
+// unwrap returns the errors wrapped by err, as errors.Unwrap and errors.Join
+// understand them.
+func unwrap(err error) []error {
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if wrapped := u.Unwrap(); wrapped != nil {
+			return []error{wrapped}
+		}
+	case interface{ Unwrap() []error }:
+		var wrapped []error
+		for _, e := range u.Unwrap() {
+			if e != nil {
+				wrapped = append(wrapped, e)
+			}
+		}
+		return wrapped
+	}
+	return nil
+}
+
+// errorStruct returns the struct holding the fields of the error v, if any.
+func errorStruct(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, v.Kind() == reflect.Struct
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// scrubErrorText removes from the Error() text of err the values of the
+// redacted fields of the errors of its chain, then applies the error
+// scrubbers.
+func (o *options) scrubErrorText(err error, text string) string {
+	replacements := make(map[string]string)
+	queue := []error{err}
+	for layers := 0; len(queue) > 0 && layers < maxErrorLayers; layers++ {
+		e := queue[0]
+		queue = append(queue[1:], unwrap(e)...)
+		if sv, ok := errorStruct(reflect.ValueOf(e)); ok {
+			o.scrubReplacements(replacements, sv, "", 0)
+		}
+	}
+	if len(replacements) > 0 {
+		values := make([]string, 0, len(replacements))
+		for value := range replacements {
+			values = append(values, value)
+		}
+		// longer values first, so that they are not partially replaced
+		sort.Slice(values, func(i, j int) bool {
+			if len(values[i]) != len(values[j]) {
+				return len(values[i]) > len(values[j])
+			}
+			return values[i] < values[j]
+		})
+		oldnew := make([]string, 0, 2*len(values))
+		for _, value := range values {
+			oldnew = append(oldnew, value, replacements[value])
+		}
+		text = strings.NewReplacer(oldnew...).Replace(text)
+	}
+	for _, scrubber := range o.redact.errorScrubbers {
+		text = scrubber(text)
+	}
+	return text
+}
+
+// scrubReplacements adds to replacements the redacted forms of the values
+// held by v, by their representation as formatted by the fmt package, if v is
+// redacted with mode or holds redacted fields. Booleans are never replaced.
+func (o *options) scrubReplacements(replacements map[string]string, v reflect.Value, mode string, depth int) {
+	if depth > maxErrorLayers {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			o.scrubReplacements(replacements, v.Elem(), mode, depth+1)
+		}
+	case reflect.Struct:
+		opts := o.forStruct(v.Type())
+		for i := 0; i < v.NumField(); i++ {
+			fieldMode := mode
+			if tag, ok := opts.redactTag(v.Type().Field(i)); ok {
+				fieldMode = tag
+			}
+			o.scrubReplacements(replacements, v.Field(i), fieldMode, depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			o.scrubReplacements(replacements, reflect.ValueOf(string(v.Bytes())), mode, depth+1)
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			o.scrubReplacements(replacements, v.Index(i), mode, depth+1)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			o.scrubReplacements(replacements, iter.Key(), mode, depth+1)
+			o.scrubReplacements(replacements, iter.Value(), mode, depth+1)
+		}
+	default:
+		if mode == "" || mode == KEEP {
+			return
+		}
+		value, ok := formatScalar(v)
+		if !ok || value == "" {
+			return
+		}
+		replacement := "<" + o.redact.replacementPlaceholder + ">"
+		if mode == MASK {
+			replacement = o.maskString(value)
+		}
+		replacements[value] = replacement
+	}
+}
+
+// formatScalar returns the string or number v as formatted by the fmt
+// package, and false if v is neither.
+func formatScalar(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), true
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,108 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+type userError struct {
+	Email string `redact:"REPLACE"`
+	Name  string `redact:"MASK"`
+	Err   error
+}
+
+func (e *userError) Error() string {
+	return fmt.Sprintf("bad user %s (%s): %v", e.Name, e.Email, e.Err)
+}
+
+func (e *userError) Unwrap() error { return e.Err }
+
+type pinError struct {
+	PIN     int `redact:"REPLACE"`
+	Account struct {
+		Holder string `redact:"MASK"`
+		Token  []byte `redact:"REPLACE"`
+		Active bool   `redact:"REPLACE"`
+	}
+}
+
+func (e pinError) Error() string {
+	return fmt.Sprintf("pin %d rejected for %s (%s, active: %t)", e.PIN, e.Account.Holder, e.Account.Token, e.Account.Active)
+}
+
+type panickingError struct{}
+
+func (panickingError) Error() string { panic("boom") }
+
+func TestErrorRendering(t *testing.T) {
+	t.Parallel()
+
+	notFound := errors.New("not found")
+	uerr := &userError{Email: "bob@example.com", Name: "Bob Smith", Err: notFound}
+	wrapped := fmt.Errorf("load: %w", uerr)
+
+	type result struct {
+		Err error
+	}
+	type hidden struct {
+		err error
+	}
+
+	for i, tc := range []struct {
+		a interface{}
+		s string
+	}{
+		{result{notFound}, `render.result{Err:(*errors.errorString)("not found")}`},
+		{result{fmt.Errorf("load: %w", notFound)}, `render.result{Err:(*fmt.wrapError)("load: not found", (*errors.errorString)("not found"))}`},
+		{result{errors.Join(notFound, errors.New("denied"))}, `render.result{Err:(*errors.joinError)("not found\ndenied", (*errors.errorString)("not found"), (*errors.errorString)("denied"))}`},
+		{result{wrapped}, `render.result{Err:(*fmt.wrapError)("load: bad user ####Smith (<redacted>): not found", ` +
+			`(*render.userError)("bad user ####Smith (<redacted>): not found"){Email:<redacted>, Name:"####Smith", Err:(*errors.errorString)("not found")})}`},
+		{result{}, `render.result{Err:error(nil)}`},
+		{result{panickingError{}}, `render.result{Err:render.panickingError{}}`},
+		// errors reached through unexported fields are rendered alike
+		{hidden{fmt.Errorf("wrap: %w", notFound)}, `render.hidden{err:(*fmt.wrapError)("wrap: not found", (*errors.errorString)("not found"))}`},
+		{hidden{uerr}, `render.hidden{err:(*render.userError)("bad user ####Smith (<redacted>): not found"){Email:<redacted>, Name:"####Smith", Err:(*errors.errorString)("not found")}}`},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), tc.a, tc.s, WithErrorRendering())
+	}
+
+	// the Error() texts are shown in clear with Render
+	m, err := NewMarshaller(WithErrorRendering())
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+	if act, exp := m.Render(uerr), `(*render.userError)("bad user Bob Smith (bob@example.com): not found"){Email:"bob@example.com", Name:"Bob Smith", Err:(*errors.errorString)("not found")}`; act != exp {
+		t.Errorf("Render did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	// all tagged scalars are scrubbed, including the ones of nested structs,
+	// but booleans
+	perr := pinError{PIN: 4321}
+	perr.Account.Holder = "Bob Smith"
+	perr.Account.Token = []byte("tok_123")
+	perr.Account.Active = true
+	assertRedactsLike(t, "nested", perr,
+		`render.pinError("pin <redacted> rejected for ####Smith (<redacted>, active: true)"){PIN:<redacted>, Account:struct { Holder string "redact:\"MASK\""; Token []uint8 "redact:\"REPLACE\""; Active bool "redact:\"REPLACE\"" }{"####Smith", <redacted>, <redacted>}}`,
+		WithErrorRendering())
+
+	// scrubbers apply to the texts of errors which do not carry the data
+	emails := regexp.MustCompile(`[a-z]+@[a-z.]+`)
+	assertRedactsLike(t, "scrubber", fmt.Errorf("bad user %v", struct{ Email string }{"bob@example.com"}),
+		`(*errors.errorString)("bad user {<email>}")`,
+		WithErrorRendering(), WithErrorScrubber(func(s string) string { return emails.ReplaceAllString(s, "<email>") }))
+
+	// Diff prints the errors by their scrubbed texts too
+	m, err = NewMarshaller(WithErrorRendering(), WithErrorScrubber(func(s string) string { return emails.ReplaceAllString(s, "<email>") }))
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+	if act, exp := m.Diff(hidden{errors.New("bad user bob@example.com")}, hidden{errors.New("bad user eve@example.com")}),
+		`~ .err: (*errors.errorString)("bad user <email>") -> (*errors.errorString)("bad user <email>")`; act != exp {
+		t.Errorf("Diff did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	// errors are rendered by their structure by default
+	assertRedactsLike(t, "default", result{notFound}, `render.result{Err:(*errors.errorString){s:"not found"}}`)
+}
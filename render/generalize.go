@@ -0,0 +1,159 @@
+package render
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time truncations of the GENERALIZE redact mode, besides durations
+const (
+	TruncateYear  = "YEAR"
+	TruncateMonth = "MONTH"
+	TruncateDay   = "DAY"
+)
+
+// generalization holds the parameters of the GENERALIZE redact mode.
+type generalization struct {
+	// bucket is the size of the buckets numbers are rounded down to, 0
+	// rounding them to their order of magnitude.
+	bucket float64
+	// prefix is the number of leading characters kept in strings.
+	prefix int
+	// truncate is the granularity times are truncated to.
+	truncate string
+}
+
+var defaultGeneralization = generalization{
+	bucket:   0,
+	prefix:   2,
+	truncate: TruncateDay,
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// WithGeneralization lets you set the default parameters used when the
+// redacting mode is set to "GENERALIZE", as a semicolon separated list of:
+//
+// - bucket=<size>: numbers are rounded down to a multiple of size, or to
+// their order of magnitude if not set
+//
+// - prefix=<length>: strings keep their first length characters, the others
+// being masked. The default length is 2
+//
+// - time=<granularity>: time.Time values are truncated to the YEAR, MONTH,
+// DAY, or to a duration such as "1h". The default granularity is DAY
+//
+// The parameters may also be set per field, e.g.:
+//
+//	Age  int       `redact:"GENERALIZE(bucket=10)"`
+//	Zip  string    `redact:"GENERALIZE(prefix=3)"`
+//	Seen time.Time `redact:"GENERALIZE(time=MONTH)"`
+func WithGeneralization(params string) MarshallerOption {
+	return func(m *Marshaller) error {
+		g, err := parseGeneralization(m.options.redact.generalization, params)
+		if err != nil {
+			return err
+		}
+		m.options.redact.generalization = g
+		return nil
+	}
+}
+
+// parseGeneralization returns g updated with the parameters params.
+func parseGeneralization(g generalization, params string) (generalization, error) {
+	for _, param := range strings.Split(params, ";") {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(param, "=")
+		switch key {
+		case "bucket":
+			bucket, err := strconv.ParseFloat(value, 64)
+			if err != nil || !(bucket > 0) {
+				return g, fmt.Errorf("invalid generalization bucket %q: must be a positive number", value)
+			}
+			g.bucket = bucket
+		case "prefix":
+			prefix, err := strconv.Atoi(value)
+			if err != nil || prefix < 0 {
+				return g, fmt.Errorf("invalid generalization prefix %q: must be a positive integer", value)
+			}
+			g.prefix = prefix
+		case "time":
+			switch value {
+			case TruncateYear, TruncateMonth, TruncateDay:
+			default:
+				if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+					return g, fmt.Errorf("invalid generalization time %q: must be YEAR, MONTH, DAY or a positive duration", value)
+				}
+			}
+			g.truncate = value
+		default:
+			return g, fmt.Errorf("unknown generalization parameter %q", key)
+		}
+	}
+	return g, nil
+}
+
+// withGeneralization returns a copy of the options generalizing masked values
+// with the parameters given to the GENERALIZE mode. Invalid parameters are
+// ignored.
+func (o *options) withGeneralization(params string) *options {
+	opts := *o
+	opts.redact.generalizing = true
+	if g, err := parseGeneralization(o.redact.generalization, params); err == nil {
+		opts.redact.generalization = g
+	}
+	return &opts
+}
+
+// withoutGeneralization returns a copy of the options masking values rather
+// than generalizing them, or the options themselves if they do not generalize.
+func (o *options) withoutGeneralization() *options {
+	if !o.redact.generalizing {
+		return o
+	}
+	opts := *o
+	opts.redact.generalizing = false
+	return &opts
+}
+
+// generalizeString writes value with only its prefix in clear.
+func (o *options) generalizeString(str *strings.Builder, value string) {
+	i := 0
+	for _, r := range value {
+		if i < o.redact.generalization.prefix {
+			str.WriteRune(r)
+		} else {
+			str.WriteRune(o.redact.maskingChar)
+		}
+		i++
+	}
+}
+
+// generalizeTime writes the time.Time v truncated to the generalization
+// granularity, the same way a type formatter would.
+func (o *options) generalizeTime(str *strings.Builder, ptrs int, v reflect.Value, implicit bool) {
+	t := v.Interface().(time.Time)
+	switch o.redact.generalization.truncate {
+	case TruncateYear:
+		t = time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	case TruncateMonth:
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case TruncateDay:
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	default:
+		d, _ := time.ParseDuration(o.redact.generalization.truncate)
+		t = t.Truncate(d)
+	}
+	if !implicit {
+		writeType(str, ptrs, v.Type())
+	}
+	str.WriteRune('(')
+	str.WriteString(t.Format(time.RFC3339))
+	str.WriteRune(')')
+}
@@ -0,0 +1,60 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGeneralize(t *testing.T) {
+	t.Parallel()
+
+	type visit struct {
+		Age    int       `redact:"GENERALIZE(bucket=10)"`
+		Salary float64   `redact:"GENERALIZE"`
+		Zip    string    `redact:"GENERALIZE(prefix=3)"`
+		City   string    `redact:"GENERALIZE"`
+		Seen   time.Time `redact:"GENERALIZE(time=MONTH)"`
+		At     time.Time `redact:"GENERALIZE(time=1h)"`
+		Place  struct {
+			Lat  float64
+			Name string `redact:"MASK"`
+		} `redact:"GENERALIZE(bucket=0.5)"`
+		Tags []string `redact:"GENERALIZE(prefix=1)"`
+	}
+	v := visit{
+		Age:    37,
+		Salary: 54321.5,
+		Zip:    "75011",
+		City:   "Paris",
+		Seen:   time.Date(2024, 5, 17, 13, 42, 0, 0, time.UTC),
+		At:     time.Date(2024, 5, 17, 13, 42, 0, 0, time.UTC),
+		Tags:   []string{"vip", "new"},
+	}
+	v.Place.Lat = 48.8566
+	v.Place.Name = "Louvre"
+
+	for i, tc := range []struct {
+		opts []MarshallerOption
+		s    string
+	}{
+		{nil, `render.visit{Age:30, Salary:10000, Zip:"750##", City:"Pa###", Seen:time.Time(2024-05-01T00:00:00Z), At:time.Time(2024-05-17T13:00:00Z), ` +
+			`Place:struct { Lat float64; Name string "redact:\"MASK\"" }{48.5, "####re"}, Tags:[]string{"v##", "n##"}}`},
+		{[]MarshallerOption{WithGeneralization("bucket=1000;prefix=0;time=YEAR")}, `render.visit{Age:30, Salary:54000, Zip:"750##", City:"#####", Seen:time.Time(2024-05-01T00:00:00Z), At:time.Time(2024-05-17T13:00:00Z), ` +
+			`Place:struct { Lat float64; Name string "redact:\"MASK\"" }{48.5, "####re"}, Tags:[]string{"v##", "n##"}}`},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), v, tc.s, tc.opts...)
+	}
+
+	// times are generalized whatever their formatter
+	assertRedactsLike(t, "formatter", struct {
+		T time.Time `redact:"GENERALIZE"`
+	}{v.Seen}, `struct { T time.Time "redact:\"GENERALIZE\"" }{T:time.Time(2024-05-17T00:00:00Z)}`,
+		WithTypeFormatter("time.Time", func(t interface{}) string { return t.(time.Time).String() }))
+
+	for _, params := range []string{"bucket=0", "prefix=-1", "time=WEEK", "time=-1h", "other=1"} {
+		if _, err := NewMarshaller(WithGeneralization(params)); err == nil {
+			t.Errorf("Expected an error on the generalization %q", params)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package render
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// minHashKeyLength is the minimum length of the keys set with WithHashKey.
+const minHashKeyLength = 16
+
+// WithHashKey lets you set the secret key used when the redacting mode is set
+// to "HASH". Values are then rendered as "<hmac:...>", the truncated
+// HMAC-SHA256 of their representation, so that equal values can be correlated
+// without the hashes being reversible by brute force over small domains such
+// as card numbers, PINs or emails.
+//
+// The key must be at least 16 bytes long. Without key, HASH fields are
+// replaced like REPLACE ones.
+func WithHashKey(key []byte) MarshallerOption {
+	return func(m *Marshaller) error {
+		if len(key) < minHashKeyLength {
+			return errors.Errorf("invalid hash key: must be at least %d bytes long", minHashKeyLength)
+		}
+		m.options.redact.hashKey = append([]byte(nil), key...)
+		return nil
+	}
+}
+
+// hash returns the keyed hash replacing v, or the replacement placeholder if
+// no hash key is set.
+func (o *options) hash(v reflect.Value) string {
+	if o.redact.hashKey == nil {
+		return "<" + o.redact.replacementPlaceholder + ">"
+	}
+	mac := hmac.New(sha256.New, o.redact.hashKey)
+	mac.Write([]byte(o.clear().renderString(v, false)))
+	return "<hmac:" + hex.EncodeToString(mac.Sum(nil)[:8]) + ">"
+}
@@ -0,0 +1,32 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHashKey(t *testing.T) {
+	t.Parallel()
+
+	type account struct {
+		Email string `redact:"HASH"`
+		PIN   int    `redact:"HASH"`
+	}
+
+	for i, tc := range []struct {
+		a    interface{}
+		s    string
+		opts []MarshallerOption
+	}{
+		{account{"bob@example.com", 1234}, `render.account{Email:<hmac:b70c3ba10e84fbd2>, PIN:<hmac:0d7432fcc9d51408>}`, []MarshallerOption{WithHashKey(testHashKey)}},
+		{account{"bob@example.com", 1234}, `render.account{Email:<hmac:88556fa03d59257c>, PIN:<hmac:8e9e8e5ab666125b>}`, []MarshallerOption{WithHashKey([]byte("fedcba9876543210"))}},
+		// without key, HASH behaves like REPLACE
+		{account{"bob@example.com", 1234}, `render.account{Email:<redacted>, PIN:<redacted>}`, nil},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), tc.a, tc.s, tc.opts...)
+	}
+
+	if _, err := NewMarshaller(WithHashKey([]byte("short"))); err == nil {
+		t.Errorf("Expected an error for a short hash key")
+	}
+}
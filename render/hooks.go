@@ -0,0 +1,110 @@
+package render
+
+import (
+	"expvar"
+	"strings"
+)
+
+// WithRedactionHook lets you register a hook called on every redaction
+// applied by Redact, RedactFor, RedactContext and RedactWithReport, e.g. to
+// count how often each kind of secret is redacted. Hooks are called in the
+// order they were registered, synchronously, so they must be fast and safe
+// for concurrent use if the marshaller is.
+//
+// See RedactionCounter for a ready-made hook.
+func WithRedactionHook(hook func(Redaction)) MarshallerOption {
+	return func(m *Marshaller) error {
+		hooks := m.options.redact.hooks
+		m.options.redact.hooks = append(hooks[:len(hooks):len(hooks)], hook)
+		return nil
+	}
+}
+
+// RedactionCounter is a redaction hook counting redactions in an expvar.Map,
+// under the following keys:
+//
+//	total         all redactions
+//	mode:<mode>   redactions per mode, e.g. "mode:MASK"
+//	source:<src>  redactions per rule source, e.g. "source:tag"
+//	type:<type>   redactions per field type, e.g. "type:string"
+//	path:<path>   redactions per field path, e.g. "path:.Users[*].Password"
+//
+// Slice indexes and map keys are written "[*]" in paths, so that the number of
+// counters does not grow with the data redacted.
+//
+// Example:
+//
+//	counter := render.NewRedactionCounter()
+//	expvar.Publish("redactions", counter.Map())
+//	m, err := render.NewMarshaller(render.WithRedactionHook(counter.Hook))
+type RedactionCounter struct {
+	counts expvar.Map
+}
+
+// NewRedactionCounter creates an empty RedactionCounter.
+func NewRedactionCounter() *RedactionCounter {
+	c := &RedactionCounter{}
+	c.counts.Init()
+	return c
+}
+
+// Hook counts r. It is meant to be registered with WithRedactionHook.
+func (c *RedactionCounter) Hook(r Redaction) {
+	c.counts.Add("total", 1)
+	c.counts.Add("mode:"+r.Mode, 1)
+	c.counts.Add("source:"+r.Source, 1)
+	c.counts.Add("type:"+r.Type, 1)
+	c.counts.Add("path:"+genericPath(r.Path), 1)
+}
+
+// genericPath returns path with its indexes and map keys written "[*]".
+func genericPath(path string) string {
+	var b strings.Builder
+	depth, quoted, escaped := 0, false, false
+	for _, r := range path {
+		switch {
+		case depth == 0:
+			if r == '[' {
+				b.WriteString("[*]")
+				depth++
+			} else {
+				b.WriteRune(r)
+			}
+		case quoted:
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '"':
+				quoted = false
+			}
+		case r == '"':
+			quoted = true
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		}
+	}
+	return b.String()
+}
+
+// Map returns the counts, e.g. to publish them with expvar.Publish.
+func (c *RedactionCounter) Map() *expvar.Map {
+	return &c.counts
+}
+
+// Count returns the count stored under key, see RedactionCounter for the
+// available keys.
+func (c *RedactionCounter) Count(key string) int64 {
+	if v, ok := c.counts.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// String implements expvar.Var.
+func (c *RedactionCounter) String() string {
+	return c.counts.String()
+}
@@ -0,0 +1,94 @@
+package render
+
+import (
+	"testing"
+)
+
+func TestRedactionHooks(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		Name     string `redact:"MASK"`
+		Password string `redact:"REPLACE"`
+		Secret   string `redact:"REMOVE"`
+	}
+
+	var paths []string
+	counter := NewRedactionCounter()
+	m, err := NewMarshaller(
+		WithRedactionHook(func(r Redaction) { paths = append(paths, r.Path) }),
+		WithRedactionHook(counter.Hook),
+	)
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+
+	m.Redact([]user{{Name: "alice"}, {Name: "bob"}})
+	m.Render(user{Name: "carol"})
+
+	exp := []string{"[0].Name", "[0].Password", "[0].Secret", "[1].Name", "[1].Password", "[1].Secret"}
+	if len(paths) != len(exp) {
+		t.Fatalf("Unexpected hook calls: %v", paths)
+	}
+	for i := range exp {
+		if paths[i] != exp[i] {
+			t.Errorf("Hook call #%d: expected path %s, got %s", i, exp[i], paths[i])
+		}
+	}
+
+	for key, exp := range map[string]int64{
+		"total":            6,
+		"mode:MASK":        2,
+		"mode:REMOVE":      2,
+		"source:tag":       6,
+		"type:string":      6,
+		"path:[*].Secret":  2,
+		"path:[1].Secret":  0,
+		"path:.Unexisting": 0,
+	} {
+		if act := counter.Count(key); act != exp {
+			t.Errorf("Count(%q): expected %d, got %d", key, exp, act)
+		}
+	}
+	for path, exp := range map[string]string{
+		".User.Password":                  ".User.Password",
+		"[12].Password":                   "[*].Password",
+		`.Users[3].Keys[<redacted>].Hash`: ".Users[*].Keys[*].Hash",
+		`.Keys["a]\"[b"].Secret`:          ".Keys[*].Secret",
+		`.Keys[[2]int{1, 2}].Secret`:      ".Keys[*].Secret",
+	} {
+		if act := genericPath(path); act != exp {
+			t.Errorf("genericPath(%s): expected %s, got %s", path, exp, act)
+		}
+	}
+	if counter.String() == "" || counter.Map().Get("total") == nil {
+		t.Errorf("Counter is not exposed as an expvar.Var")
+	}
+}
+
+func TestRedactionHooksSortedKeys(t *testing.T) {
+	t.Parallel()
+
+	type key struct {
+		Name string `redact:"REPLACE"`
+	}
+	keys := map[*key]int{}
+	for i, name := range []string{"h", "c", "a", "f", "b", "g", "e", "d"} {
+		keys[&key{name}] = i
+	}
+
+	counter := NewRedactionCounter()
+	m, err := NewMarshaller(WithStableMapOrdering(), WithRedactionHook(counter.Hook))
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+
+	// sorting the keys must not fire the hooks nor report redactions
+	_, report := m.RedactWithReport(keys)
+	if len(report.Redactions) != len(keys) {
+		t.Errorf("Expected %d redactions, got %d: %+v", len(keys), len(report.Redactions), report.Redactions)
+	}
+	if act := counter.Count("total"); act != int64(len(keys)) {
+		t.Errorf("Count(\"total\"): expected %d, got %d", len(keys), act)
+	}
+}
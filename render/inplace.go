@@ -0,0 +1,336 @@
+package render
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RedactInPlace scrubs the value pointed to by ptr according to the redact
+// tags, without copying it:
+//
+// - `redact:"REMOVE"` fields are zeroed
+//
+// - `redact:"REPLACE"` string and interface fields are set to the
+// "<redacted>" placeholder, other fields are zeroed
+//
+// - `redact:"HASH"` string and interface fields are set to the hash of their
+// value, other fields are zeroed
+//
+// - `redact:"TOKENIZE"` string and interface fields are set to a token, other
+// fields are zeroed
+//
+// - `redact:"ENCRYPT"` string and interface fields are set to a ciphertext,
+// other fields are zeroed
+//
+// - `redact:"MASK"` strings are masked and other values are zeroed
+//
+// - `redact:"GENERALIZE"` strings only keep their generalization prefix in
+// clear, other values are treated like MASK ones
+//
+// - the members of slices, arrays and maps with element policies are redacted
+// like fields with the same tag, elided elements being replaced. Map keys
+// replaced by the same placeholder collapse into a single entry
+//
+// Values held by interfaces and maps are scrubbed on a copy which then
+// replaces the original one. Fields which need to be scrubbed but cannot be
+// set, such as unexported fields, are left untouched and reported in the
+// returned error, one per field path.
+func (m *Marshaller) RedactInPlace(ptr interface{}) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("RedactInPlace requires a non-nil pointer")
+	}
+	s := &scrubber{
+		opts: m.redactOptions(),
+		seen: make(map[copyKey]bool),
+	}
+	s.scrub("", v, s.opts.redact.allowlist, s.opts)
+	if err := s.opts.flush(); err != nil {
+		s.errs = append(s.errs, err)
+	}
+	return s.err()
+}
+
+// RedactInPlace scrubs the value pointed to by ptr. See
+// Marshaller.RedactInPlace for more details.
+func RedactInPlace(ptr interface{}) error {
+	m := newDefaultMarshaller()
+	return m.RedactInPlace(ptr)
+}
+
+// scrubber holds the state of a single RedactInPlace call.
+type scrubber struct {
+	opts *options
+	seen map[copyKey]bool
+	errs []error
+}
+
+// err returns the errors met while scrubbing, one per line, or nil if there
+// were none.
+func (s *scrubber) err() error {
+	switch len(s.errs) {
+	case 0:
+		return nil
+	case 1:
+		return s.errs[0]
+	}
+	msgs := make([]string, len(s.errs))
+	for i, err := range s.errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// set sets v to x, or records why it could not.
+func (s *scrubber) set(path string, v, x reflect.Value) {
+	switch {
+	case v.CanSet():
+		v.Set(x)
+	case v.CanAddr():
+		s.errs = append(s.errs, errors.Errorf("%s: cannot scrub unexported field", displayPath(path)))
+	default:
+		s.errs = append(s.errs, errors.Errorf("%s: cannot scrub unaddressable value", displayPath(path)))
+	}
+}
+
+// scrub scrubs v in place and returns true if it was modified.
+func (s *scrubber) scrub(path string, v reflect.Value, mask bool, opts *options) (modified bool) {
+	t := v.Type()
+	switch t.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return false
+		}
+		key := copyKey{ptr: v.Pointer(), t: t}
+		if s.seen[key] {
+			return false
+		}
+		s.seen[key] = true
+		return s.scrub(path, v.Elem(), mask, opts)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return false
+		}
+		e := v.Elem()
+		if mask && isNumeric(e.Kind()) {
+			x := reflect.Zero(t)
+			if masked := reflect.ValueOf(opts.maskNumberString(e)); masked.Type().AssignableTo(t) {
+				x = masked
+			}
+			s.set(path, v, x)
+			return true
+		}
+		if e.Kind() == reflect.Ptr {
+			return s.scrub(path, e, mask, opts)
+		}
+		if !v.CanSet() {
+			return s.unsettable(path, v, e.Type(), mask || opts.redact.elements != nil)
+		}
+		n := reflect.New(e.Type()).Elem()
+		n.Set(e)
+		if s.scrub(path, n, mask, opts) {
+			v.Set(n)
+			return true
+		}
+		return false
+
+	case reflect.Struct:
+		opts := opts.withoutElementPolicies().forStruct(t)
+		for i := 0; i < t.NumField(); i++ {
+			f := v.Field(i)
+			fieldPath := path + "." + t.Field(i).Name
+			switch tag, param, _, _ := opts.redactRule(t.Field(i)); tag {
+			case REMOVE:
+				if !f.IsZero() {
+					s.set(fieldPath, f, reflect.Zero(f.Type()))
+					modified = true
+				}
+			case REPLACE, HASH, TOKENIZE, ENCRYPT:
+				modified = s.replace(fieldPath, f, opts.placeholder(tag, f)) || modified
+			case MASK:
+				modified = s.scrub(fieldPath, f, true, opts.withoutGeneralization()) || modified
+			case GENERALIZE:
+				modified = s.scrub(fieldPath, f, true, opts.withGeneralization(param)) || modified
+			case elementsMode:
+				modified = s.scrub(fieldPath, f, mask, opts.withElementPolicies(param)) || modified
+			default:
+				mask := mask && !opts.kept(tag, fieldPath)
+				modified = s.scrub(fieldPath, f, mask, opts) || modified
+			}
+		}
+		return modified
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return false
+		}
+		key := copyKey{ptr: v.Pointer(), t: t, len: v.Len()}
+		if s.seen[key] {
+			return false
+		}
+		s.seen[key] = true
+		fallthrough
+
+	case reflect.Array:
+		elements := opts.redact.elements
+		opts = opts.withoutElementPolicies()
+		for i := 0; i < v.Len(); i++ {
+			modified = s.scrubElement(path+"["+strconv.Itoa(i)+"]", v.Index(i), mask, elements.elemPolicy(i, v.Len()), opts) || modified
+		}
+		return modified
+
+	case reflect.Map:
+		if v.IsNil() {
+			return false
+		}
+		key := copyKey{ptr: v.Pointer(), t: t}
+		if s.seen[key] {
+			return false
+		}
+		s.seen[key] = true
+		if !v.CanSet() {
+			return s.unsettable(path, v, t, mask || opts.redact.elements != nil)
+		}
+		elements := opts.redact.elements
+		opts = opts.withoutElementPolicies()
+		if mode := elements.keyPolicy(); mode != "" && mode != KEEP {
+			// redacted keys may collide, so the map is rebuilt
+			m := reflect.MakeMapWithSize(t, v.Len())
+			for _, k := range v.MapKeys() {
+				keyPath := path + "[" + (*traverseState)(nil).redactKey(k, false, mode, opts) + "]"
+				nk := reflect.New(t.Key()).Elem()
+				nk.Set(k)
+				s.scrubElement(keyPath, nk, false, mode, opts)
+				n := reflect.New(t.Elem()).Elem()
+				n.Set(v.MapIndex(k))
+				s.scrubElement(keyPath, n, mask, elements.valuePolicy(), opts)
+				m.SetMapIndex(nk, n)
+			}
+			v.Set(m)
+			return true
+		}
+		for _, k := range v.MapKeys() {
+			n := reflect.New(t.Elem()).Elem()
+			n.Set(v.MapIndex(k))
+			if s.scrubElement(path+"["+opts.renderString(k, false)+"]", n, mask, elements.valuePolicy(), opts) {
+				v.SetMapIndex(k, n)
+				modified = true
+			}
+		}
+		return modified
+
+	case reflect.String:
+		if !mask {
+			return false
+		}
+		if masked := opts.maskString(v.String()); masked != v.String() {
+			s.set(path, v, reflect.ValueOf(masked).Convert(t))
+			return true
+		}
+		return false
+	}
+
+	if mask && !v.IsZero() {
+		s.set(path, v, reflect.Zero(t))
+		return true
+	}
+	return false
+}
+
+// scrubElement scrubs the member v of a slice, array or map according to the
+// mode of its element policy, see renderElement, and returns true if it was
+// modified.
+func (s *scrubber) scrubElement(path string, v reflect.Value, mask bool, policy string, opts *options) bool {
+	switch mode, param := splitModeParam(policy); mode {
+	case "":
+		return s.scrub(path, v, mask, opts)
+	case KEEP:
+		return s.scrub(path, v, mask && !opts.kept(mode, ""), opts)
+	case MASK:
+		return s.scrub(path, v, true, opts.withoutGeneralization())
+	case GENERALIZE:
+		return s.scrub(path, v, true, opts.withGeneralization(param))
+	case HASH, TOKENIZE, ENCRYPT:
+		return s.replace(path, v, opts.placeholder(mode, v))
+	}
+	return s.replace(path, v, opts.placeholder(REPLACE, v))
+}
+
+// replace sets v to placeholder, or to its zero value if it cannot hold it,
+// and returns true if it was modified.
+func (s *scrubber) replace(path string, v reflect.Value, placeholder string) bool {
+	x := replacement(v.Type(), placeholder)
+	if isReplaced(v, x) {
+		return false
+	}
+	s.set(path, v, x)
+	return true
+}
+
+// replacement returns placeholder as a value of type t, or the zero value of
+// t if it cannot hold it.
+func replacement(t reflect.Type, placeholder string) reflect.Value {
+	p := reflect.ValueOf(placeholder)
+	switch {
+	case t.Kind() == reflect.String:
+		return p.Convert(t)
+	case t.Kind() == reflect.Interface && p.Type().AssignableTo(t):
+		x := reflect.New(t).Elem()
+		x.Set(p)
+		return x
+	}
+	return reflect.Zero(t)
+}
+
+// isReplaced returns true if v already holds the replacement x.
+func isReplaced(v, x reflect.Value) bool {
+	switch {
+	case v.Kind() == reflect.String:
+		return v.String() == x.String()
+	case v.Kind() == reflect.Interface && !x.IsNil():
+		return !v.IsNil() && v.Elem().Kind() == reflect.String && v.Elem().String() == x.Elem().String()
+	}
+	return v.IsZero()
+}
+
+// unsettable records an error for the value v, which cannot be set, if
+// values of type t may hold data to scrub.
+func (s *scrubber) unsettable(path string, v reflect.Value, t reflect.Type, mask bool) bool {
+	if mask || s.sensitive(t, make(map[reflect.Type]bool)) {
+		s.set(path, v, reflect.Zero(v.Type()))
+		return true
+	}
+	return false
+}
+
+// sensitive returns true if values of type t may contain tagged fields.
+// Interfaces are not followed since their dynamic type is unknown.
+func (s *scrubber) sensitive(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return s.sensitive(t.Elem(), seen)
+	case reflect.Map:
+		return s.sensitive(t.Elem(), seen)
+	case reflect.Struct:
+		if s.opts.typePolicy(t) != "" {
+			return true
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if _, ok := s.opts.redactTag(t.Field(i)); ok {
+				return true
+			}
+			if s.sensitive(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
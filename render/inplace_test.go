@@ -0,0 +1,95 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactInPlace(t *testing.T) {
+	t.Parallel()
+
+	type inner struct {
+		Token string `redact:"MASK"`
+	}
+	type testStruct struct {
+		Name     string
+		Password string      `redact:"REPLACE"`
+		Pin      int         `redact:"REPLACE"`
+		Any      interface{} `redact:"REPLACE"`
+		Secret   string      `redact:"REMOVE"`
+		Token    string      `redact:"MASK"`
+		Count    int         `redact:"MASK"`
+		Inners   map[string]inner
+		Boxed    interface{}
+		Next     *testStruct
+		m        string
+	}
+
+	v := &testStruct{
+		Name:     "foo",
+		Password: "hunter2",
+		Pin:      1234,
+		Any:      42,
+		Secret:   "s3cr3t",
+		Token:    "abcdefgh",
+		Count:    123456,
+		Inners:   map[string]inner{"a": {Token: "abcdef"}},
+		Boxed:    inner{Token: "abcdef"},
+		m:        "unexported",
+	}
+	v.Next = v
+
+	if err := RedactInPlace(v); err != nil {
+		t.Fatalf("RedactInPlace failed: %v", err)
+	}
+	exp := `(*render.testStruct){Name:"foo", Password:"<redacted>", Pin:0, Any:"<redacted>", Secret:"", ` +
+		`Token:"####efgh", Count:0, Inners:map[string]render.inner{"a":render.inner{Token:"####ef"}}, ` +
+		`Boxed:render.inner{Token:"####ef"}, Next:<recursive(*render.testStruct)>, m:"unexported"}`
+	if act := Render(v); act != exp {
+		t.Errorf("RedactInPlace did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	type person struct {
+		Email string      `redact:"GENERALIZE(prefix=2)"`
+		Age   interface{} `redact:"GENERALIZE(bucket=10)"`
+	}
+	p := &person{"joe@example.com", 34}
+	if err := RedactInPlace(p); err != nil {
+		t.Errorf("RedactInPlace failed: %v", err)
+	} else if act, exp := Render(p), `(*render.person){Email:"jo#############", Age:"30"}`; act != exp {
+		t.Errorf("RedactInPlace did not generalize:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	type vault struct {
+		Keys map[string]string `redact:"keys=REPLACE,values=REPLACE"`
+		Vals map[string]string `redact:"values=REPLACE"`
+		List []string          `redact:"first=1,last=1"`
+	}
+	vv := &vault{map[string]string{"alice@x.com": "s3cr3t"}, map[string]string{"apikey": "s3cr3t"}, []string{"a", "b", "c", "d"}}
+	if err := RedactInPlace(vv); err != nil {
+		t.Errorf("RedactInPlace failed: %v", err)
+	} else if act, exp := Render(vv), `(*render.vault){Keys:map[string]string{"<redacted>":"<redacted>"}, `+
+		`Vals:map[string]string{"apikey":"<redacted>"}, List:[]string{"a", "<redacted>", "<redacted>", "d"}}`; act != exp {
+		t.Errorf("RedactInPlace did not apply the element policies:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	type unexported struct {
+		secret string `redact:"REPLACE"`
+		inner  inner
+		empty  string `redact:"REMOVE"`
+	}
+	u := &unexported{secret: "foo", inner: inner{Token: "abcdef"}}
+	err := RedactInPlace(u)
+	if err == nil {
+		t.Fatalf("RedactInPlace should have failed on unexported fields")
+	}
+	for _, path := range []string{".secret: cannot scrub unexported field", ".inner.Token: cannot scrub unexported field"} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("Expected error to contain %q, got: %v", path, err)
+		}
+	}
+
+	if err := RedactInPlace(testStruct{}); err == nil {
+		t.Errorf("RedactInPlace should have failed on a non-pointer")
+	}
+}
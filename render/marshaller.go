@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/pkg/errors"
 )
@@ -20,9 +21,45 @@ const (
 
 // Redact modes
 const (
-	REMOVE  = "REMOVE"
-	REPLACE = "REPLACE"
-	MASK    = "MASK"
+	REMOVE     = "REMOVE"
+	REPLACE    = "REPLACE"
+	MASK       = "MASK"
+	HASH       = "HASH"
+	TOKENIZE   = "TOKENIZE"
+	ENCRYPT    = "ENCRYPT"
+	GENERALIZE = "GENERALIZE"
+	KEEP       = "KEEP"
+)
+
+// DefaultAudience is the audience whose policy applies to the audiences not
+// listed in a redact tag, and to Redact.
+const DefaultAudience = "default"
+
+// Unexported fields visibilities
+const (
+	// ShowUnexported renders unexported fields like exported ones
+	ShowUnexported = "SHOW"
+	// HideUnexported never renders unexported fields
+	HideUnexported = "HIDE"
+	// RenderOnlyUnexported renders unexported fields with Render but not with
+	// Redact
+	RenderOnlyUnexported = "RENDER_ONLY"
+	// ReplaceUnexported treats unexported fields without redact tag as if they
+	// were tagged REPLACE
+	ReplaceUnexported = "REPLACE"
+)
+
+// Number masking strategies
+const (
+	// MaskNumberString replaces numbers by their masked representation as a
+	// quoted string, e.g. 123456 as "####56"
+	MaskNumberString = "STRING"
+	// MaskNumberRound rounds numbers down to a multiple of the bucket size
+	// given as "ROUND=size", e.g. ages to decades with "ROUND=10", or to their
+	// order of magnitude without size
+	MaskNumberRound = "ROUND"
+	// MaskNumberZero replaces numbers by zero
+	MaskNumberZero = "ZERO"
 )
 
 // Marshaller allow to configure options for rendering or redacting
@@ -31,8 +68,8 @@ type Marshaller struct {
 }
 
 var defaultRenderOptions = renderOptions{
-	typeFormatters:       make(map[string]func(interface{}) string),
 	recursionPlaceholder: DefaultRecursionPlaceholder,
+	unexportedFields:     ShowUnexported,
 }
 var defaultRedactOptions = redactOptions{
 	active:                 false,
@@ -41,15 +78,21 @@ var defaultRedactOptions = redactOptions{
 	maskingChar:            DefaultMaskingChar,
 	maskingLength:          DefaultMaskingLength,
 	maskingReverse:         false,
+	generalization:         defaultGeneralization,
+	audience:               DefaultAudience,
 }
 
 func newDefaultMarshaller() *Marshaller {
-	return &Marshaller{
+	m := &Marshaller{
 		options: &options{
 			render: defaultRenderOptions,
 			redact: defaultRedactOptions,
 		},
 	}
+	// each marshaller gets its own formatters so that registering one does not
+	// affect the others
+	m.options.render.typeFormatters = make(map[string]func(interface{}) string)
+	return m
 }
 
 // MarshallerOption configures the Marshaller
@@ -115,6 +158,126 @@ func WithRecursionPlaceholder(recursionPlaceholder string) MarshallerOption {
 	}
 }
 
+// WithPointerPlaceholder lets you set the placeholder rendered instead of the
+// address of channels, functions and unsafe pointers, which changes from one
+// run to another.
+//
+// By default, the address is rendered as an hexadecimal number.
+func WithPointerPlaceholder(pointerPlaceholder string) MarshallerOption {
+	return func(m *Marshaller) error {
+		m.options.render.pointerPlaceholder = pointerPlaceholder
+		return nil
+	}
+}
+
+// WithStableMapOrdering lets you order map keys which would otherwise be
+// ordered by memory address (pointers and channels) by their rendered
+// representation, so that the output is the same from one run to another.
+func WithStableMapOrdering() MarshallerOption {
+	return func(m *Marshaller) error {
+		m.options.render.stableMapOrdering = true
+		return nil
+	}
+}
+
+// WithGoSyntaxRender lets values wrapped by Marshaller.Safe be rendered
+// without redaction when formatted with the %#v verb, all other verbs being
+// still redacted.
+func WithGoSyntaxRender() MarshallerOption {
+	return func(m *Marshaller) error {
+		m.options.render.goSyntaxRender = true
+		return nil
+	}
+}
+
+// WithFieldNameTag lets you label struct fields with the name given by the
+// tag key fieldNameTag (e.g. "json", "yaml" or "db") instead of their Go name.
+// Fields without a name in this tag fall back to their Go name, and fields
+// whose tag is "-" are omitted.
+//
+// Example:
+//  WithFieldNameTag("json")
+//  // UserID int `json:"user_id"` renders as user_id:42
+func WithFieldNameTag(fieldNameTag string) MarshallerOption {
+	return func(m *Marshaller) error {
+		err := validateTag(fieldNameTag)
+		if err != nil {
+			return errors.Wrap(err, "invalid field name tag")
+		}
+		m.options.render.fieldNameTag = fieldNameTag
+		return nil
+	}
+}
+
+// WithOmitEmpty lets you omit struct fields having the "omitempty" option in
+// the tag set by WithFieldNameTag when their value is empty, as understood by
+// encoding/json: false, 0, nil pointers and interfaces, and empty arrays,
+// slices, maps and strings.
+func WithOmitEmpty() MarshallerOption {
+	return func(m *Marshaller) error {
+		m.options.render.omitEmpty = true
+		return nil
+	}
+}
+
+// WithOmitZeroFields lets you omit struct fields holding the zero value of
+// their type.
+func WithOmitZeroFields() MarshallerOption {
+	return func(m *Marshaller) error {
+		m.options.render.omitZero = true
+		return nil
+	}
+}
+
+// WithOmitNilFields lets you omit struct fields holding a nil pointer, slice,
+// map, interface, channel or function.
+func WithOmitNilFields() MarshallerOption {
+	return func(m *Marshaller) error {
+		m.options.render.omitNil = true
+		return nil
+	}
+}
+
+// WithOmitEmptyCollections lets you omit struct fields holding a nil or empty
+// slice or map, or an empty array.
+func WithOmitEmptyCollections() MarshallerOption {
+	return func(m *Marshaller) error {
+		m.options.render.omitEmptyCollections = true
+		return nil
+	}
+}
+
+// WithFlattenEmbedded lets you render the fields of embedded structs as if
+// they were fields of the embedding struct, the way Go promotes them. Fields
+// shadowed by a field of the embedding struct are not rendered.
+//
+// Redact tags on the embedding field apply to all promoted fields, e.g. an
+// embedded Credentials field tagged `redact:"MASK"` masks all the fields of
+// Credentials, and one tagged `redact:"HASH"` hashes each of them.
+func WithFlattenEmbedded() MarshallerOption {
+	return func(m *Marshaller) error {
+		m.options.render.flattenEmbedded = true
+		return nil
+	}
+}
+
+// WithUnexportedFields lets you set the visibility of unexported fields, which
+// may hold internal state such as cached tokens and usually cannot be tagged
+// by the consumer of a type: ShowUnexported, HideUnexported,
+// RenderOnlyUnexported or ReplaceUnexported.
+//
+// The default visibility is ShowUnexported
+func WithUnexportedFields(visibility string) MarshallerOption {
+	return func(m *Marshaller) error {
+		switch visibility {
+		case ShowUnexported, HideUnexported, RenderOnlyUnexported, ReplaceUnexported:
+			m.options.render.unexportedFields = visibility
+			return nil
+		}
+		return fmt.Errorf("invalid unexported fields visibility: %q", visibility)
+	}
+}
+
 // WithRedactTag lets you set the tag used to specify struct fields to redact
 //
 // The default value for this tag is "redact"
@@ -176,6 +339,81 @@ func WithMaskingReverse() MarshallerOption {
 	}
 }
 
+// WithFormatPreservingMask lets you mask only the characters of the given
+// classes when the redacting mode is set to "MASK", leaving the others, such
+// as separators, intact. The masking length then counts the masked
+// characters only.
+//
+// Without classes, letters and digits are masked.
+//
+// Example:
+//  WithFormatPreservingMask() // "+1 (555) 123-4567" -> "+# (###) 123-4567"
+//  WithFormatPreservingMask(unicode.Digit)
+func WithFormatPreservingMask(classes ...*unicode.RangeTable) MarshallerOption {
+	return func(m *Marshaller) error {
+		if len(classes) == 0 {
+			classes = []*unicode.RangeTable{unicode.Letter, unicode.Digit}
+		}
+		m.options.redact.maskedClasses = classes
+		return nil
+	}
+}
+
+// WithDigitSubstitution lets you substitute masked digits by the given digit
+// rather than by the masking character when the redacting mode is set to
+// "MASK", so that masked values keep their numeric shape, e.g. for fields
+// consumed by validators.
+func WithDigitSubstitution(digit rune) MarshallerOption {
+	return func(m *Marshaller) error {
+		if digit < '0' || digit > '9' {
+			return fmt.Errorf("invalid substitution digit %q: must be between '0' and '9'", digit)
+		}
+		m.options.redact.maskingDigit = digit
+		return nil
+	}
+}
+
+// WithNumberMasking lets you set how numbers are masked when the redacting
+// mode is set to "MASK", so that masked numbers remain valid numbers of their
+// type if needed: MaskNumberString, MaskNumberRound, optionally followed by
+// "=size", or MaskNumberZero. Bools are set to false by the last two.
+//
+// The strategy may also be set per field as a parameter of the mode:
+//  Age    int     `redact:"MASK(ROUND=10)"`
+//  Amount float64 `redact:"MASK(ROUND)"`
+//  Score  int     `redact:"MASK(ZERO)"`
+//  PIN    int     `redact:"MASK(STRING)"`
+//
+// Without strategy, the representation of numbers is masked like a string but
+// left unquoted, e.g. 123456 as ####56, which is not a valid number.
+func WithNumberMasking(strategy string) MarshallerOption {
+	return func(m *Marshaller) error {
+		masking, bucket, err := parseNumberMasking(strategy)
+		if err != nil {
+			return errors.Wrap(err, "invalid number masking")
+		}
+		m.options.redact.numberMasking = masking
+		m.options.redact.numberBucket = bucket
+		return nil
+	}
+}
+
+// WithMapKeys lets you redact the keys of the maps whose values are masked,
+// e.g. by a MASK or GENERALIZE field, with the given mode: MASK, REPLACE, HASH,
+// TOKENIZE or ENCRYPT. Maps are still ordered by their original keys.
+//
+// By default, map keys are rendered in clear. RedactValue and RedactInPlace
+// always keep map keys.
+func WithMapKeys(mode string) MarshallerOption {
+	return func(m *Marshaller) error {
+		if mode != MASK && !isPlaceholderMode(mode) {
+			return fmt.Errorf("invalid map keys mode %q: must be MASK, REPLACE, HASH, TOKENIZE or ENCRYPT", mode)
+		}
+		m.options.redact.mapKeys = mode
+		return nil
+	}
+}
+
 // Render converts a structure to a string representation. Unlike the "%#v"
 // format string, this resolves pointer types' contents in structs, maps, and
 // slices/arrays and prints their field values.
@@ -201,13 +439,78 @@ func (m *Marshaller) Render(v interface{}) string {
 // - `redact:"MASK"` will mask by the character '#' 4 characters of the value
 // if its a builtin type, or of its members values if it is a
 // slice/array/map/struct.
+//
+// - `redact:"HASH"` will replace the value of the field by the
+// "<hmac:...>" keyed hash of its representation, see WithHashKey
+//
+// - `redact:"TOKENIZE"` will replace the value of the field by a "<tok:...>"
+// token which can be reverted with Detokenize, see WithVault
+//
+// - `redact:"ENCRYPT"` will replace the value of the field by an
+// "<enc:...>" ciphertext which can be reverted with Decrypt, see
+// WithKeyProvider
+//
+// - `redact:"GENERALIZE"` will round numbers, truncate times and keep the
+// prefix of strings of the value, see WithGeneralization
+//
+// - `redact:"keys=HASH,values=MASK"` will redact the keys and the values of a
+// map, or the elements of a slice or array with "elems", separately with any
+// of the above modes or KEEP, REMOVE replacing them. "first=N" and "last=N"
+// only render the first and last N elements of a slice or array, eliding the
+// others
+//
+// The tag may also hold per audience policies, see RedactFor.
+//
+// Tokens the vault fails to store are replaced by the replacement
+// placeholder, use RedactContext to get the errors of the vault.
 func (m *Marshaller) Redact(v interface{}) string {
 	str := strings.Builder{}
 	s := (*traverseState)(nil)
-	opts := m.options
+	opts := m.redactOptions()
+	s.render(&str, 0, reflect.ValueOf(v), false, opts.redact.allowlist, opts)
+	redacted, _ := opts.flushString(str.String())
+	return redacted
+}
+
+// RedactFor redacts v for the given audience: the tags of struct fields may
+// hold a comma separated list of audience=mode policies, the mode of the
+// "default" audience applying to unlisted audiences. Fields without policy for
+// the audience, and without default, are replaced like REPLACE ones.
+//
+// Example:
+//  type Card struct {
+//    Number string `redact:"support=MASK,audit=HASH,default=REMOVE"`
+//  }
+//  m.RedactFor("support", card) // masks the number
+//  m.RedactFor("debug", card)   // removes the number
+func (m *Marshaller) RedactFor(audience string, v interface{}) string {
+	str := strings.Builder{}
+	s := (*traverseState)(nil)
+	opts := m.redactOptions()
+	opts.redact.audience = audience
+	s.render(&str, 0, reflect.ValueOf(v), false, opts.redact.allowlist, opts)
+	redacted, _ := opts.flushString(str.String())
+	return redacted
+}
+
+// redactOptions returns a copy of the marshaller options with redaction
+// activated, leaving the marshaller itself untouched.
+func (m *Marshaller) redactOptions() *options {
+	opts := *m.options
 	opts.redact.active = true
-	s.render(&str, 0, reflect.ValueOf(v), false, false, opts)
-	return str.String()
+	if len(opts.redact.hooks) > 0 || len(opts.redact.allowedPaths) > 0 {
+		opts.recorder = &recorder{}
+	}
+	if opts.redact.allowlist {
+		opts.redact.maskingLength = -1
+		if opts.redact.mapKeys == "" {
+			opts.redact.mapKeys = MASK
+		}
+	}
+	if opts.redact.vault != nil {
+		opts.tokens = &tokenBatch{vault: opts.redact.vault}
+	}
+	return &opts
 }
 
 var tagRegexString = "^[a-zA-Z0-9_-]+$"
@@ -0,0 +1,130 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// parseNumberMasking parses a number masking strategy such as "ROUND=10"
+// into the strategy and its bucket size.
+func parseNumberMasking(strategy string) (string, float64, error) {
+	name, size, hasSize := strings.Cut(strategy, "=")
+	switch {
+	case name == MaskNumberRound && hasSize:
+		bucket, err := strconv.ParseFloat(size, 64)
+		if err != nil || !(bucket > 0) || math.IsInf(bucket, 0) {
+			return "", 0, fmt.Errorf("bucket size must be a positive number, got %q", size)
+		}
+		return name, bucket, nil
+	case hasSize:
+		return "", 0, fmt.Errorf("%s does not take a size", name)
+	case name == MaskNumberString || name == MaskNumberRound || name == MaskNumberZero:
+		return name, 0, nil
+	}
+	return "", 0, fmt.Errorf("unknown strategy %q", strategy)
+}
+
+// withNumberMasking returns a copy of the options using the number masking
+// strategy given as a parameter of the MASK mode, or the options themselves
+// if the parameter is invalid.
+func (o *options) withNumberMasking(param string) *options {
+	masking, bucket, err := parseNumberMasking(param)
+	if err != nil {
+		return o
+	}
+	opts := *o
+	opts.redact.numberMasking = masking
+	opts.redact.numberBucket = bucket
+	return &opts
+}
+
+// maskNumber writes the masked bool or number v, whose representation is
+// value, according to the number masking strategy.
+func (o *options) maskNumber(str *strings.Builder, v reflect.Value, value string) {
+	if !o.redact.active {
+		str.WriteString(value)
+		return
+	}
+	if v.Kind() == reflect.Bool {
+		// true and false must not be told apart by the length of their mask
+		value = strings.Repeat(string(o.redact.maskingChar), len("false"))
+	}
+	switch {
+	case o.redact.generalizing && v.Kind() != reflect.Bool:
+		writeScalar(str, roundValue(v, o.redact.generalization.bucket))
+	case o.redact.numberMasking == MaskNumberZero:
+		writeScalar(str, reflect.Zero(v.Type()))
+	case o.redact.numberMasking == MaskNumberRound:
+		writeScalar(str, roundValue(v, o.redact.numberBucket))
+	case o.redact.numberMasking == MaskNumberString:
+		str.WriteString(strconv.Quote(o.maskString(value)))
+	default:
+		o.mask(str, value)
+	}
+}
+
+// roundValue returns v rounded down to a multiple of bucket, or to its order
+// of magnitude if bucket is 0. Bools are returned as false.
+func roundValue(v reflect.Value, bucket float64) reflect.Value {
+	r := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		r.SetInt(roundInt(v.Int(), bucket))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		r.SetUint(roundUint(v.Uint(), bucket))
+	case reflect.Float32, reflect.Float64:
+		r.SetFloat(roundFloat(v.Float(), bucket))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		r.SetComplex(complex(roundFloat(real(c), bucket), roundFloat(imag(c), bucket)))
+	}
+	return r
+}
+
+func roundInt(x int64, bucket float64) int64 {
+	switch {
+	case bucket == 0 && x < 0:
+		return -int64(roundUint(uint64(-x), 0))
+	case bucket == 0:
+		return int64(roundUint(uint64(x), 0))
+	case bucket != math.Trunc(bucket) || bucket > math.MaxInt64:
+		return int64(roundFloat(float64(x), bucket))
+	}
+	size := int64(bucket)
+	q := x / size
+	if x%size != 0 && x < 0 {
+		q--
+	}
+	return q * size
+}
+
+func roundUint(x uint64, bucket float64) uint64 {
+	switch {
+	case bucket == 0:
+		if x == 0 {
+			return 0
+		}
+		p := uint64(1)
+		for p <= x/10 {
+			p *= 10
+		}
+		return p
+	case bucket != math.Trunc(bucket) || bucket > math.MaxUint64:
+		return uint64(roundFloat(float64(x), bucket))
+	}
+	size := uint64(bucket)
+	return x / size * size
+}
+
+func roundFloat(x float64, bucket float64) float64 {
+	switch {
+	case x == 0 || math.IsInf(x, 0) || math.IsNaN(x):
+		return x
+	case bucket == 0:
+		return math.Copysign(math.Pow(10, math.Floor(math.Log10(math.Abs(x)))), x)
+	}
+	return math.Floor(x/bucket) * bucket
+}
@@ -0,0 +1,778 @@
+package render
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// Parse errors
+var (
+	// ErrRedacted is returned by Parse when the input contains a redaction
+	// placeholder (e.g. "<redacted>") in place of a value.
+	ErrRedacted = errors.New("redacted value cannot be restored")
+	// ErrRecursive is returned by Parse when the input contains a recursion
+	// marker (e.g. "<recursive(*pkg.T)>") in place of a value.
+	ErrRecursive = errors.New("recursive value cannot be restored")
+	// ErrUnsupported is returned by Parse for values which cannot be restored
+	// from their rendered form, such as channels, functions or values written
+	// by a type formatter.
+	ErrUnsupported = errors.New("value cannot be restored")
+)
+
+// ParseError describes where and why Parse failed.
+type ParseError struct {
+	Offset int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("render: parse error at offset %d: %v", e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error, so that errors.Is can match
+// ErrRedacted, ErrRecursive and ErrUnsupported.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Parse reconstructs a value from its Render representation and stores it in
+// the value pointed to by out.
+//
+// Parse understands the syntax emitted by Render: explicit and implicit
+// types, pointer parentheses, maps, slices, arrays, structs and nil markers.
+// Types named in the input are resolved against the types reachable from out,
+// so interfaces can be restored as long as their dynamic type is reachable
+// from the target type or built from builtin types. Implicitly typed numbers
+// stored in an interface are restored as int, float64 or complex128.
+//
+// Redaction placeholders, recursion markers, masked numbers, channels,
+// functions and type formatter outputs cannot be restored: Parse returns a
+// *ParseError wrapping ErrRedacted, ErrRecursive or ErrUnsupported.
+func Parse(s string, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("render: Parse requires a non-nil pointer")
+	}
+	p := &parser{s: s, types: make(map[string]reflect.Type)}
+	p.register(rv.Type().Elem())
+	for _, t := range builtinTypes {
+		p.register(t)
+	}
+	if err := p.value(rv.Elem(), false); err != nil {
+		return err
+	}
+	if p.pos != len(p.s) {
+		return p.errorf("unexpected trailing data %q", p.s[p.pos:])
+	}
+	return nil
+}
+
+var builtinTypes = []reflect.Type{
+	reflect.TypeOf(false),
+	reflect.TypeOf(complex128(0)),
+	reflect.TypeOf(complex64(0)),
+	reflect.TypeOf(float32(0)),
+	reflect.TypeOf(float64(0)),
+	reflect.TypeOf(int16(0)),
+	reflect.TypeOf(int32(0)),
+	reflect.TypeOf(int64(0)),
+	reflect.TypeOf(int8(0)),
+	reflect.TypeOf(int(0)),
+	reflect.TypeOf(""),
+	reflect.TypeOf(uint16(0)),
+	reflect.TypeOf(uint32(0)),
+	reflect.TypeOf(uint64(0)),
+	reflect.TypeOf(uint8(0)),
+	reflect.TypeOf(uint(0)),
+	reflect.TypeOf(uintptr(0)),
+	reflect.TypeOf((*interface{})(nil)).Elem(),
+}
+
+// parser holds the state of a single Parse call.
+type parser struct {
+	s   string
+	pos int
+	// types maps the rendered name of every known type to the type itself.
+	types map[string]reflect.Type
+}
+
+func typeString(ptrs int, t reflect.Type) string {
+	str := strings.Builder{}
+	writeType(&str, ptrs, t)
+	return str.String()
+}
+
+// register records t and every type reachable from it, so that the names
+// found in the input can be resolved when restoring interfaces.
+func (p *parser) register(t reflect.Type) {
+	name := typeString(0, t)
+	if _, ok := p.types[name]; ok {
+		return
+	}
+	p.types[name] = t
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		p.register(t.Elem())
+	case reflect.Map:
+		p.register(t.Key())
+		p.register(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			p.register(t.Field(i).Type)
+		}
+	}
+}
+
+// resolve returns the type rendered as name.
+func (p *parser) resolve(name string) (reflect.Type, error) {
+	if t, ok := p.types[name]; ok {
+		return t, nil
+	}
+	switch {
+	case strings.HasPrefix(name, "*"):
+		elem, err := p.resolve(name[1:])
+		if err != nil {
+			return nil, err
+		}
+		return reflect.PtrTo(elem), nil
+
+	case strings.HasPrefix(name, "[]"):
+		elem, err := p.resolve(name[2:])
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elem), nil
+
+	case strings.HasPrefix(name, "["):
+		end := strings.IndexByte(name, ']')
+		if end > 0 {
+			n, err := strconv.Atoi(name[1:end])
+			if err == nil {
+				elem, err := p.resolve(name[end+1:])
+				if err != nil {
+					return nil, err
+				}
+				return reflect.ArrayOf(n, elem), nil
+			}
+		}
+
+	case strings.HasPrefix(name, "map["):
+		end, err := scanType(name, len("map["))
+		if err == nil && end < len(name) && name[end] == ']' {
+			key, err := p.resolve(name[len("map["):end])
+			if err != nil {
+				return nil, err
+			}
+			elem, err := p.resolve(name[end+1:])
+			if err != nil {
+				return nil, err
+			}
+			return reflect.MapOf(key, elem), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown type %q", name)
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &ParseError{Offset: p.pos, Err: fmt.Errorf(format, args...)}
+}
+
+func (p *parser) fail(err error, format string, args ...interface{}) error {
+	return &ParseError{Offset: p.pos, Err: fmt.Errorf("%w: "+format, append([]interface{}{err}, args...)...)}
+}
+
+func (p *parser) peek(prefix string) bool {
+	return strings.HasPrefix(p.s[p.pos:], prefix)
+}
+
+func (p *parser) consume(prefix string) bool {
+	if p.peek(prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(prefix string) error {
+	if !p.consume(prefix) {
+		return p.errorf("expected %q, found %q", prefix, p.excerpt())
+	}
+	return nil
+}
+
+// peekWord returns true if the input continues with w, not followed by an
+// identifier character.
+func (p *parser) peekWord(w string) bool {
+	if !p.peek(w) {
+		return false
+	}
+	end := p.pos + len(w)
+	return end >= len(p.s) || !isIdentRune(rune(p.s[end]))
+}
+
+func (p *parser) consumeWord(w string) bool {
+	if p.peekWord(w) {
+		p.pos += len(w)
+		return true
+	}
+	return false
+}
+
+func (p *parser) excerpt() string {
+	const max = 16
+	if len(p.s)-p.pos > max {
+		return p.s[p.pos:p.pos+max] + "..."
+	}
+	return p.s[p.pos:]
+}
+
+// placeholder reports a redaction placeholder or a recursion marker.
+func (p *parser) placeholder() error {
+	end, err := scanBalanced(p.s, p.pos, '<', '>')
+	if err != nil {
+		return p.errorf("unterminated placeholder")
+	}
+	text := p.s[p.pos:end]
+	if strings.HasSuffix(text, ")>") {
+		return p.fail(ErrRecursive, "%s", text)
+	}
+	return p.fail(ErrRedacted, "%s", text)
+}
+
+// value parses a value into v, mirroring traverseState.render.
+func (p *parser) value(v reflect.Value, implicit bool) error {
+	if p.peek("<") {
+		return p.placeholder()
+	}
+	t := v.Type()
+	switch t.Kind() {
+	case reflect.Ptr:
+		if p.consumeWord("nil") {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		return p.pointer(v)
+
+	case reflect.Interface:
+		return p.iface(v)
+
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return p.fail(ErrUnsupported, "%s", t)
+	}
+
+	if name := typeString(0, t); p.peek(name) {
+		if end := p.pos + len(name); end < len(p.s) && (p.s[end] == '{' || p.s[end] == '(') {
+			p.pos = end
+			return p.body(v, true)
+		}
+	}
+	if !implicit && !isBuiltinScalar(t) {
+		return p.errorf("expected type %s, found %q", typeString(0, t), p.excerpt())
+	}
+	return p.body(v, false)
+}
+
+// pointer parses a pointer value rendered as "(*T)(...)" or "(*T){...}".
+func (p *parser) pointer(v reflect.Value) error {
+	start := p.pos
+	if !p.consume("(*") {
+		return p.errorf("expected pointer to %s, found %q", v.Type().Elem(), p.excerpt())
+	}
+	stars := 1
+	for p.consume("*") {
+		stars++
+	}
+	end, err := scanBalanced(p.s, start, '(', ')')
+	if err != nil {
+		return p.errorf("unterminated pointer type")
+	}
+	base := p.s[p.pos : end-1]
+	p.pos = end
+	if p.consume("(nil)") {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	return p.pointee(v, stars, base)
+}
+
+// pointee parses the value found behind stars pointers whose innermost type
+// is rendered as base.
+func (p *parser) pointee(v reflect.Value, stars int, base string) error {
+	switch {
+	case stars == 0:
+		if v.Kind() == reflect.Interface && typeString(0, v.Type()) != base {
+			t, err := p.resolve(base)
+			if err != nil {
+				return p.errorf("%v", err)
+			}
+			e := reflect.New(t).Elem()
+			if err := p.pointee(e, 0, base); err != nil {
+				return err
+			}
+			return p.assign(v, e)
+		}
+		if name := typeString(0, v.Type()); name != base {
+			return p.errorf("type mismatch: expected %s, found %s", name, base)
+		}
+		return p.body(v, true)
+
+	case v.Kind() == reflect.Ptr:
+		e := reflect.New(v.Type().Elem())
+		if err := p.pointee(e.Elem(), stars-1, base); err != nil {
+			return err
+		}
+		v.Set(e)
+		return nil
+
+	case v.Kind() == reflect.Interface:
+		t, err := p.resolve(strings.Repeat("*", stars) + base)
+		if err != nil {
+			return p.errorf("%v", err)
+		}
+		e := reflect.New(t).Elem()
+		if err := p.pointee(e, stars, base); err != nil {
+			return err
+		}
+		return p.assign(v, e)
+	}
+	return p.errorf("type mismatch: expected %s, found %s%s", v.Type(), strings.Repeat("*", stars), base)
+}
+
+// iface parses a value whose static type is an interface.
+func (p *parser) iface(v reflect.Value) error {
+	t := v.Type()
+	switch {
+	case p.consumeWord("nil"):
+		v.Set(reflect.Zero(t))
+		return nil
+
+	case p.peek("(*"):
+		return p.pointer(v)
+
+	case p.peek("(chan"), p.peek("(func"), p.peek("(unsafe.Pointer)"):
+		return p.fail(ErrUnsupported, "%s", p.excerpt())
+
+	case p.peek("\""), p.peek("("), p.peekWord("true"), p.peekWord("false"):
+		// Implicit builtin value.
+		return p.literal(v)
+	}
+	if r := p.s[p.pos:]; r != "" && (r[0] == '-' || r[0] == '+' || r[0] == '.' || unicode.IsDigit(rune(r[0])) ||
+		strings.HasPrefix(r, "NaN") || strings.HasPrefix(r, "Inf")) {
+		return p.literal(v)
+	}
+
+	end, err := scanType(p.s, p.pos)
+	if err != nil || end == p.pos {
+		return p.errorf("expected value, found %q", p.excerpt())
+	}
+	name := p.s[p.pos:end]
+	dt, err := p.resolve(name)
+	if err != nil {
+		return p.errorf("%v", err)
+	}
+	if dt.Kind() == reflect.Interface {
+		p.pos = end
+		if err := p.expect("(nil)"); err != nil {
+			return err
+		}
+		v.Set(reflect.Zero(t))
+		return nil
+	}
+	e := reflect.New(dt).Elem()
+	if err := p.value(e, false); err != nil {
+		return err
+	}
+	return p.assign(v, e)
+}
+
+func (p *parser) assign(v, e reflect.Value) error {
+	if !e.Type().AssignableTo(v.Type()) {
+		return p.errorf("type %s is not assignable to %s", e.Type(), v.Type())
+	}
+	v.Set(e)
+	return nil
+}
+
+// body parses the value of v once its type, if any, has been consumed.
+func (p *parser) body(v reflect.Value, typed bool) error {
+	t := v.Type()
+	switch t.Kind() {
+	case reflect.Struct:
+		return p.structFields(v)
+
+	case reflect.Slice:
+		if p.consume("(nil)") || (!typed && p.consumeWord("nil")) {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		fallthrough
+
+	case reflect.Array:
+		return p.elements(v)
+
+	case reflect.Map:
+		if p.consume("(nil)") || (!typed && p.consumeWord("nil")) {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		return p.mapEntries(v)
+
+	case reflect.Interface:
+		if err := p.expect("(nil)"); err != nil {
+			return err
+		}
+		v.Set(reflect.Zero(t))
+		return nil
+
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return p.fail(ErrUnsupported, "%s", t)
+	}
+
+	if !typed {
+		return p.literal(v)
+	}
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	if err := p.literal(v); err != nil {
+		return err
+	}
+	return p.expect(")")
+}
+
+func (p *parser) structFields(v reflect.Value) error {
+	if p.peek("(") {
+		return p.fail(ErrUnsupported, "%s may have been rendered by a type formatter", v.Type())
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	t := v.Type()
+	structAnon := t.Name() == ""
+	next := 0
+	for first := true; !p.consume("}"); first = false {
+		if !first {
+			if err := p.expect(", "); err != nil {
+				return err
+			}
+		}
+		i := -1
+		if name, ok := p.fieldName(); ok {
+			for j := 0; j < t.NumField(); j++ {
+				if t.Field(j).Name == name {
+					i = j
+					break
+				}
+			}
+			if i < 0 {
+				return p.errorf("unknown field %s in %s", name, t)
+			}
+			p.pos += len(name) + 1
+		} else {
+			for j := next; j < t.NumField(); j++ {
+				if structAnon && isAnonType(t.Field(j).Type) {
+					i = j
+					break
+				}
+			}
+			if i < 0 {
+				return p.errorf("unexpected value %q in %s", p.excerpt(), t)
+			}
+		}
+		next = i + 1
+		anon := structAnon && isAnonType(t.Field(i).Type)
+		if err := p.value(settable(v.Field(i)), anon); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settable returns a settable version of the addressable field f, even when
+// it is unexported.
+func settable(f reflect.Value) reflect.Value {
+	if f.CanSet() {
+		return f
+	}
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+}
+
+// fieldName returns the struct field name at the current position, if any.
+func (p *parser) fieldName() (string, bool) {
+	i := p.pos
+	for i < len(p.s) && isIdentRune(rune(p.s[i])) {
+		i++
+	}
+	if i == p.pos || i >= len(p.s) || p.s[i] != ':' || unicode.IsDigit(rune(p.s[p.pos])) {
+		return "", false
+	}
+	return p.s[p.pos:i], true
+}
+
+func (p *parser) elements(v reflect.Value) error {
+	if p.peek("(") {
+		return p.fail(ErrUnsupported, "%s may have been rendered by a type formatter", v.Type())
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	t := v.Type()
+	anon := t.Name() == "" && isAnonType(t.Elem())
+	elems := reflect.MakeSlice(reflect.SliceOf(t.Elem()), 0, 0)
+	for first := true; !p.consume("}"); first = false {
+		if !first {
+			if err := p.expect(", "); err != nil {
+				return err
+			}
+		}
+		e := reflect.New(t.Elem()).Elem()
+		if err := p.value(e, anon); err != nil {
+			return err
+		}
+		elems = reflect.Append(elems, e)
+	}
+	if t.Kind() == reflect.Slice {
+		v.Set(elems.Convert(t))
+		return nil
+	}
+	if elems.Len() != t.Len() {
+		return p.errorf("expected %d elements for %s, found %d", t.Len(), t, elems.Len())
+	}
+	reflect.Copy(v, elems)
+	return nil
+}
+
+func (p *parser) mapEntries(v reflect.Value) error {
+	if p.peek("(") {
+		return p.fail(ErrUnsupported, "%s may have been rendered by a type formatter", v.Type())
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	t := v.Type()
+	kt := t.Key()
+	keyAnon := typeOfString.ConvertibleTo(kt) || typeOfInt.ConvertibleTo(kt) || typeOfUint.ConvertibleTo(kt) || typeOfFloat.ConvertibleTo(kt)
+	valAnon := t.Name() == "" && isAnonType(t.Elem())
+	m := reflect.MakeMap(t)
+	for first := true; !p.consume("}"); first = false {
+		if !first {
+			if err := p.expect(", "); err != nil {
+				return err
+			}
+		}
+		k := reflect.New(kt).Elem()
+		if err := p.value(k, keyAnon); err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		e := reflect.New(t.Elem()).Elem()
+		if err := p.value(e, valAnon); err != nil {
+			return err
+		}
+		m.SetMapIndex(k, e)
+	}
+	v.Set(m)
+	return nil
+}
+
+// literal parses a builtin literal into v. If v is an interface, the literal
+// is stored using its default Go type.
+func (p *parser) literal(v reflect.Value) error {
+	start := p.pos
+	if p.peek("<") {
+		return p.placeholder()
+	}
+	var token string
+	switch {
+	case p.peek("\""):
+		end, err := scanQuoted(p.s, p.pos)
+		if err != nil {
+			return p.errorf("unterminated string")
+		}
+		token, p.pos = p.s[p.pos:end], end
+	case p.peek("("):
+		end, err := scanBalanced(p.s, p.pos, '(', ')')
+		if err != nil {
+			return p.errorf("unterminated complex number")
+		}
+		token, p.pos = p.s[p.pos:end], end
+	default:
+		end := p.pos
+		for end < len(p.s) && !strings.ContainsRune(",:)}", rune(p.s[end])) {
+			end++
+		}
+		token, p.pos = p.s[p.pos:end], end
+	}
+
+	fail := func(err error) error {
+		p.pos = start
+		if strings.ContainsRune(token, DefaultMaskingChar) {
+			return p.fail(ErrRedacted, "cannot parse %q as %s", token, v.Type())
+		}
+		return p.errorf("cannot parse %q as %s: %v", token, v.Type(), err)
+	}
+
+	t := v.Type()
+	if t.Kind() == reflect.Interface {
+		var e interface{}
+		switch {
+		case strings.HasPrefix(token, "\""):
+			e = ""
+		case strings.HasPrefix(token, "("):
+			e = complex128(0)
+		case token == "true" || token == "false":
+			e = false
+		case strings.ContainsAny(token, ".eEIN"):
+			e = float64(0)
+		default:
+			e = int(0)
+		}
+		ev := reflect.New(reflect.TypeOf(e)).Elem()
+		p.pos = start
+		if err := p.literal(ev); err != nil {
+			return err
+		}
+		return p.assign(v, ev)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		s, err := strconv.Unquote(token)
+		if err != nil {
+			return fail(err)
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(token)
+		if err != nil {
+			return fail(err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(token, 10, t.Bits())
+		if err != nil {
+			return fail(err)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(token, 10, t.Bits())
+		if err != nil {
+			return fail(err)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(token, t.Bits())
+		if err != nil {
+			return fail(err)
+		}
+		v.SetFloat(f)
+	case reflect.Complex64, reflect.Complex128:
+		c, err := strconv.ParseComplex(token, t.Bits())
+		if err != nil {
+			return fail(err)
+		}
+		v.SetComplex(c)
+	default:
+		p.pos = start
+		return p.errorf("unexpected %q for %s", p.excerpt(), t)
+	}
+	return nil
+}
+
+func isBuiltinScalar(t reflect.Type) bool {
+	name, ok := builtinTypeMap[t.Kind()]
+	return ok && name == t.String()
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// scanQuoted returns the index following the Go quoted string starting at i.
+func scanQuoted(s string, i int) (int, error) {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1, nil
+		}
+	}
+	return 0, errors.New("unterminated string")
+}
+
+// scanBalanced returns the index following the closing delimiter matching the
+// opening one at i, skipping quoted strings.
+func scanBalanced(s string, i int, open, close byte) (int, error) {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '"':
+			end, err := scanQuoted(s, j)
+			if err != nil {
+				return 0, err
+			}
+			j = end - 1
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return j + 1, nil
+			}
+		}
+	}
+	return 0, errors.Errorf("unbalanced %q", open)
+}
+
+// scanType returns the index following the type written by writeType
+// starting at i.
+func scanType(s string, i int) (int, error) {
+	r := s[i:]
+	switch {
+	case r == "":
+		return i, nil
+	case strings.HasPrefix(r, "[]"):
+		return scanType(s, i+2)
+	case r[0] == '[':
+		end := strings.IndexByte(r, ']')
+		if end < 0 {
+			return 0, errors.New("unterminated array type")
+		}
+		return scanType(s, i+end+1)
+	case strings.HasPrefix(r, "map["):
+		end, err := scanType(s, i+len("map["))
+		if err != nil {
+			return 0, err
+		}
+		if end >= len(s) || s[end] != ']' {
+			return 0, errors.New("unterminated map type")
+		}
+		return scanType(s, end+1)
+	case r[0] == '*':
+		return scanType(s, i+1)
+	case r[0] == '(':
+		return scanBalanced(s, i, '(', ')')
+	case strings.HasPrefix(r, "interface{}"):
+		return i + len("interface{}"), nil
+	case strings.HasPrefix(r, "struct {"), strings.HasPrefix(r, "interface {"):
+		return scanBalanced(s, i+strings.IndexByte(r, '{'), '{', '}')
+	}
+	j := i
+	for j < len(s) && (isIdentRune(rune(s[j])) || s[j] == '.') {
+		j++
+	}
+	if j < len(s) && s[j] == '[' && j > i {
+		// Instantiated generic type.
+		return scanBalanced(s, j, '[', ']')
+	}
+	return j, nil
+}
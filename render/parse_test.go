@@ -0,0 +1,132 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Name string
+		I    interface{}
+		m    string
+		P    *testStruct
+	}
+	type myStringSlice []string
+	type myStringMap map[string]string
+	type myIntType int
+	type anonFields struct {
+		a int
+		b string
+	}
+
+	s0 := "string0"
+	s0P := &s0
+	mit := myIntType(42)
+
+	for i, v := range []interface{}{
+		123,
+		"hello \"quoted\"",
+		3.14,
+		complex(3, 0.14),
+		true,
+		testStruct{Name: "foo", I: &testStruct{Name: "baz", m: "m"}},
+		&testStruct{Name: "foo", I: 12, P: &testStruct{I: []string{"a"}}},
+		(*testStruct)(nil),
+		[]byte(nil),
+		[]byte{},
+		[]*testStruct{{Name: "foo"}, nil},
+		map[string]string(nil),
+		myStringSlice{"foo", "bar"},
+		myStringMap{"foo": "bar", "a:b": "c, d"},
+		myIntType(12),
+		&mit,
+		&s0,
+		&s0P,
+		struct {
+			a int
+			b string
+		}{123, "foo"},
+		[...]int{1, 2, 3},
+		map[int]string{1: "foo", 2: "bar"},
+		map[string]interface{}{"a": 1, "b": "c", "d": nil, "e": 1.5, "f": map[string]interface{}{"g": []interface{}{true}}},
+		[]interface{}{nil, 1, 2, nil},
+		[]struct{ a, b int }{{1, 2}},
+		map[myIntType]struct{}{10: {}},
+		[]anonFields{{1, "x"}},
+	} {
+		rendered := Render(v)
+		out := reflect.New(reflect.TypeOf(v))
+		if err := Parse(rendered, out.Interface()); err != nil {
+			t.Errorf("Input #%d: Parse(%s) failed: %v", i, rendered, err)
+			continue
+		}
+		if act := Render(out.Elem().Interface()); act != rendered {
+			t.Errorf("Input #%d did not round trip:\nExpected: %s\nActual  : %s\n", i, rendered, act)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Name  string `redact:"REPLACE"`
+		Token string `redact:"MASK"`
+		I     interface{}
+		C     chan int
+	}
+	rec := &testStruct{}
+	rec.I = rec
+
+	for i, tc := range []struct {
+		in  string
+		err error
+	}{
+		{Redact(testStruct{Name: "foo"}), ErrRedacted},
+		{Render(*rec), ErrRecursive},
+		{Render(testStruct{C: make(chan int)}), ErrUnsupported},
+		{`render.testStruct{Name:"", Token:"", I:interface{}(nil), C:(chan int)(nil), Other:1}`, nil},
+		{`render.testStruct{Name:"", Token:"", I:interface{}(nil)} trailing`, nil},
+	} {
+		var out testStruct
+		err := Parse(tc.in, &out)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Input #%d: expected a *ParseError, got %v", i, err)
+			continue
+		}
+		if tc.err != nil && !errors.Is(err, tc.err) {
+			t.Errorf("Input #%d: expected %v, got %v", i, tc.err, err)
+		}
+	}
+
+	type masked struct {
+		A int `redact:"MASK"`
+	}
+	var out masked
+	if err := Parse(Redact(masked{123456}), &out); !errors.Is(err, ErrRedacted) {
+		t.Errorf("expected ErrRedacted when parsing a masked number, got %v", err)
+	}
+	var n int
+	if err := Parse("1", n); err == nil {
+		t.Errorf("expected an error when parsing into a non-pointer")
+	}
+}
+
+func ExampleParse() {
+	type point struct {
+		X, Y int
+		Tags map[string]string
+	}
+	var p point
+	if err := Parse(`render.point{X:1, Y:2, Tags:map[string]string{"a":"b"}}`, &p); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(p.X, p.Y, p.Tags["a"])
+	// Output: 1 2 b
+}
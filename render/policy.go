@@ -0,0 +1,97 @@
+package render
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// RedactPolicer is implemented by the types defining the default redact mode
+// of their fields, e.g. to redact all the fields of a type but the ones tagged
+// `redact:"KEEP"`, so that fields added later are redacted too.
+//
+// RedactPolicy is called on the zero value of the type, and must return a
+// redact mode such as REPLACE, or an empty string for no default.
+//
+// Example:
+//
+//	type PaymentMethod struct {
+//		ID     string `redact:"KEEP"`
+//		Number string
+//		Holder string
+//	}
+//
+//	func (PaymentMethod) RedactPolicy() string { return render.REPLACE }
+type RedactPolicer interface {
+	RedactPolicy() string
+}
+
+var redactPolicerType = reflect.TypeOf((*RedactPolicer)(nil)).Elem()
+
+// WithTypePolicy lets you set the default redact mode of the fields of a
+// given type, e.g. for types you cannot add a RedactPolicy method to. Fields
+// tagged `redact:"KEEP"` are rendered in clear, and fields with another tag
+// are redacted according to it.
+//
+// Example:
+//
+//	WithTypePolicy("payment.Method", render.REPLACE)
+func WithTypePolicy(typeName string, mode string) MarshallerOption {
+	return func(m *Marshaller) error {
+		if err := validatePolicy(mode); err != nil {
+			return err
+		}
+		if m.options.redact.typePolicies == nil {
+			m.options.redact.typePolicies = make(map[string]string)
+		}
+		m.options.redact.typePolicies[typeName] = mode
+		return nil
+	}
+}
+
+func validatePolicy(policy string) error {
+	switch mode, _ := splitModeParam(policy); {
+	case mode == REMOVE || mode == MASK || mode == GENERALIZE || isPlaceholderMode(mode):
+		return nil
+	}
+	return fmt.Errorf("invalid type policy %q", policy)
+}
+
+// typePolicy returns the default redact mode of the fields of the struct type
+// t, if any.
+func (o *options) typePolicy(t reflect.Type) (policy string) {
+	if policy, ok := o.redact.typePolicies[t.String()]; ok {
+		return policy
+	}
+	// register a recover to avoid panicking on user provided policy
+	defer func() {
+		if panicError := recover(); panicError != nil {
+			policy = REPLACE
+		}
+	}()
+	switch {
+	case t.Implements(redactPolicerType):
+		policy = reflect.Zero(t).Interface().(RedactPolicer).RedactPolicy()
+	case reflect.PtrTo(t).Implements(redactPolicerType):
+		policy = reflect.New(t).Interface().(RedactPolicer).RedactPolicy()
+	}
+	if policy != "" && validatePolicy(policy) != nil {
+		// fail closed on invalid policies
+		return REPLACE
+	}
+	return policy
+}
+
+// forStruct returns the options to redact the fields of the struct type t
+// with, applying its policy if any.
+func (o *options) forStruct(t reflect.Type) *options {
+	if !o.redact.active {
+		return o
+	}
+	policy := o.typePolicy(t)
+	if policy == o.redact.structPolicy {
+		return o
+	}
+	opts := *o
+	opts.redact.structPolicy = policy
+	return &opts
+}
@@ -0,0 +1,83 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+)
+
+type paymentMethod struct {
+	ID     string `redact:"KEEP"`
+	Number string
+	Holder string `redact:"MASK"`
+	Expiry struct{ Month, Year int }
+}
+
+func (paymentMethod) RedactPolicy() string { return REPLACE }
+
+type auditEntry struct {
+	Actor  string `redact:"KEEP"`
+	Action string `redact:"KEEP"`
+	Detail string
+}
+
+func (*auditEntry) RedactPolicy() string { return "MASK(ZERO)" }
+
+type badPolicy struct {
+	Secret string
+}
+
+func (badPolicy) RedactPolicy() string { return "KEEP" }
+
+func TestTypePolicies(t *testing.T) {
+	t.Parallel()
+
+	type order struct {
+		Payment paymentMethod
+		Audit   *auditEntry
+		Note    string
+	}
+	type address struct {
+		Street string
+		City   string `redact:"KEEP"`
+	}
+	o := order{
+		Payment: paymentMethod{ID: "pm_1", Number: "4111111111111111", Holder: "Alice Smith"},
+		Audit:   &auditEntry{Actor: "bob", Action: "pay", Detail: "card ending 1111"},
+		Note:    "gift",
+	}
+
+	for i, tc := range []struct {
+		a    interface{}
+		s    string
+		opts []MarshallerOption
+	}{
+		{o, `render.order{Payment:render.paymentMethod{ID:"pm_1", Number:<redacted>, Holder:"####e Smith", Expiry:<redacted>}, ` +
+			`Audit:(*render.auditEntry){Actor:"bob", Action:"pay", Detail:"#### ending 1111"}, Note:"gift"}`, nil},
+		{badPolicy{"foo"}, `render.badPolicy{Secret:<redacted>}`, nil},
+		{address{"1 Main St", "Paris"}, `render.address{Street:<hmac:a2cc2f14eaf02a88>, City:"Paris"}`, []MarshallerOption{WithTypePolicy("render.address", HASH), WithHashKey(testHashKey)}},
+		{address{"1 Main St", "Paris"}, `render.address{City:"Paris"}`, []MarshallerOption{WithTypePolicy("render.address", REMOVE)}},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), tc.a, tc.s, tc.opts...)
+	}
+
+	if act, exp := Render(o.Payment), `render.paymentMethod{ID:"pm_1", Number:"4111111111111111", Holder:"Alice Smith", Expiry:struct { Month int; Year int }{0, 0}}`; act != exp {
+		t.Errorf("Render did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	copied, err := RedactValue(o)
+	if err != nil {
+		t.Fatalf("RedactValue failed: %v", err)
+	}
+	if p := copied.(order).Payment; p.ID != "pm_1" || p.Number != "<redacted>" {
+		t.Errorf("Unexpected copy: %+v", p)
+	}
+
+	_, report := RedactWithReport(o.Payment)
+	if len(report.Redactions) == 0 || report.Redactions[0].Source != SourcePolicy {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	if _, err := NewMarshaller(WithTypePolicy("render.address", KEEP)); err == nil {
+		t.Errorf("Expected an error on the KEEP type policy")
+	}
+}
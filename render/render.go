@@ -5,11 +5,14 @@
 package render
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 var builtinTypeMap = map[reflect.Kind]string{
@@ -48,11 +51,38 @@ var typeOfFloat = reflect.TypeOf(10.1)
 type options struct {
 	render renderOptions
 	redact redactOptions
+	// ctx aborts the traversal once done, when set by RedactContext.
+	ctx context.Context
+	// recorder tracks the redactions, when set by RedactWithReport or for
+	// redaction hooks.
+	recorder *recorder
+	// tokens collects the values tokenized during a single call, when a vault
+	// is set.
+	tokens *tokenBatch
+}
+
+// canceledPlaceholder is written instead of the values which have not been
+// traversed when the context of RedactContext is done.
+const canceledPlaceholder = "<canceled>"
+
+func (o *options) canceled() bool {
+	return o.ctx != nil && o.ctx.Err() != nil
 }
 
 type renderOptions struct {
 	recursionPlaceholder string
 	typeFormatters       map[string]func(interface{}) string
+	pointerPlaceholder   string
+	stableMapOrdering    bool
+	goSyntaxRender       bool
+	fieldNameTag         string
+	omitEmpty            bool
+	omitZero             bool
+	omitNil              bool
+	omitEmptyCollections bool
+	flattenEmbedded      bool
+	unexportedFields     string
+	errors               bool
 }
 type redactOptions struct {
 	active                 bool
@@ -61,6 +91,24 @@ type redactOptions struct {
 	maskingChar            rune
 	maskingLength          int
 	maskingReverse         bool
+	maskedClasses          []*unicode.RangeTable
+	maskingDigit           rune
+	numberMasking          string
+	numberBucket           float64
+	mapKeys                string
+	elements               *elementPolicies
+	typePolicies           map[string]string
+	allowlist              bool
+	allowedPaths           []*regexp.Regexp
+	errorScrubbers         []func(string) string
+	structPolicy           string
+	generalizing           bool
+	generalization         generalization
+	audience               string
+	hooks                  []func(Redaction)
+	hashKey                []byte
+	vault                  Vault
+	keys                   KeyProvider
 }
 
 // Render converts a structure to a string representation. Unlike the "%#v"
@@ -86,20 +134,50 @@ func Render(v interface{}) string {
 // - `redact:"MASK"` will mask by the character '#' 4 characters of the value
 // if its a builtin type, or of its members values if it is a
 // slice/array/map/struct.
+//
+// - `redact:"HASH"` will replace the value of the field by the
+// "<hmac:...>" keyed hash of its representation, see WithHashKey
+//
+// - `redact:"TOKENIZE"` will replace the value of the field by a "<tok:...>"
+// token which can be reverted with Detokenize, see WithVault
+//
+// - `redact:"ENCRYPT"` will replace the value of the field by an
+// "<enc:...>" ciphertext which can be reverted with Decrypt, see
+// WithKeyProvider
+//
+// - `redact:"GENERALIZE"` will round numbers, truncate times and keep the
+// prefix of strings of the value, see WithGeneralization
+//
+// - `redact:"keys=HASH,values=MASK"` will redact the keys and the values of a
+// map, or the elements of a slice or array with "elems", separately with any
+// of the above modes or KEEP, REMOVE replacing them. "first=N" and "last=N"
+// only render the first and last N elements of a slice or array, eliding the
+// others
 func Redact(v interface{}) string {
 	m := newDefaultMarshaller()
 	return m.Redact(v)
 }
 
+// RedactFor redacts v for the given audience. See Marshaller.RedactFor for
+// more details.
+func RedactFor(audience string, v interface{}) string {
+	m := newDefaultMarshaller()
+	return m.RedactFor(audience, v)
+}
+
 // renderPointer is called to render a pointer value.
 //
 // This is overridable so that the test suite can have deterministic pointer
 // values in its expectations.
 var renderPointer = func(str *strings.Builder, p uintptr, mask bool, opts *options) {
+	value := fmt.Sprintf("0x%016x", p)
+	if opts.render.pointerPlaceholder != "" {
+		value = opts.render.pointerPlaceholder
+	}
 	if mask {
-		opts.mask(str, fmt.Sprintf("0x%016x", p))
+		opts.mask(str, value)
 	} else {
-		fmt.Fprintf(str, "0x%016x", p)
+		str.WriteString(value)
 	}
 }
 
@@ -131,10 +209,20 @@ func (s *traverseState) render(str *strings.Builder, ptrs int, v reflect.Value,
 		str.WriteString("nil")
 		return
 	}
+	if opts.canceled() {
+		str.WriteString(canceledPlaceholder)
+		return
+	}
 	vt := v.Type()
 
+	// Generalized times are truncated, whatever their formatter
+	if mask && opts.redact.generalizing && vt == timeType && v.CanInterface() {
+		opts.generalizeTime(str, ptrs, v, implicit)
+		return
+	}
+
 	// If a formatter is registered for this value type, call it and return
-	if formatted := opts.callRegisteredTypeFormatter(str, ptrs, vt, v, implicit); formatted {
+	if formatted := opts.callRegisteredTypeFormatter(str, ptrs, vt, v, implicit, mask && opts.redact.allowlist); formatted {
 		return
 	}
 	// If the type being rendered is a potentially recursive type (a type that
@@ -175,13 +263,8 @@ func (s *traverseState) render(str *strings.Builder, ptrs int, v reflect.Value,
 		}
 	}
 
-	isAnon := func(t reflect.Type) bool {
-		if t.Name() != "" {
-			if _, ok := builtinTypeSet[t.Name()]; !ok {
-				return false
-			}
-		}
-		return t.Kind() != reflect.Interface
+	if opts.isError(v) && s.renderError(str, ptrs, v, implicit, mask, opts) {
+		return
 	}
 
 	switch vk {
@@ -189,23 +272,9 @@ func (s *traverseState) render(str *strings.Builder, ptrs int, v reflect.Value,
 		if !implicit {
 			writeType(str, ptrs, vt)
 		}
-		structAnon := vt.Name() == ""
 		str.WriteRune('{')
-		for i := 0; i < vt.NumField(); i++ {
-			anon := structAnon && isAnon(vt.Field(i).Type)
-			if opts.redact.active && s.redactField(str, vt, v, i, anon, mask, opts) {
-				continue
-			}
-			if i > 0 && (!opts.redact.active || !opts.isRemoved(vt.Field(i-1))) {
-				str.WriteString(", ")
-			}
-
-			if !anon {
-				str.WriteString(vt.Field(i).Name)
-				str.WriteRune(':')
-			}
-			s.render(str, 0, v.Field(i), anon, mask, opts)
-		}
+		written := false
+		s.renderFields(str, v, mask, "", &written, nil, opts.withoutElementPolicies())
 		str.WriteRune('}')
 
 	case reflect.Slice:
@@ -224,14 +293,31 @@ func (s *traverseState) render(str *strings.Builder, ptrs int, v reflect.Value,
 		if !implicit {
 			writeType(str, ptrs, vt)
 		}
-		anon := vt.Name() == "" && isAnon(vt.Elem())
+		elements := opts.redact.elements
+		opts = opts.withoutElementPolicies()
+		anon := vt.Name() == "" && isAnonType(vt.Elem())
 		str.WriteString("{")
 		for i := 0; i < v.Len(); i++ {
 			if i > 0 {
 				str.WriteString(", ")
 			}
+			if opts.canceled() {
+				str.WriteString(canceledPlaceholder)
+				break
+			}
+			if elided, n := elements.elided(i, v.Len()); elided {
+				str.WriteString("<" + strconv.Itoa(n) + " elided>")
+				i += n - 1
+				continue
+			}
 
-			s.render(str, 0, v.Index(i), anon, mask, opts)
+			opts.enter("[" + strconv.Itoa(i) + "]")
+			if elements != nil {
+				s.renderElement(str, v.Index(i), anon, mask, elements.values, opts)
+			} else {
+				s.render(str, 0, v.Index(i), anon, mask, opts)
+			}
+			opts.leave()
 		}
 		str.WriteRune('}')
 
@@ -245,19 +331,46 @@ func (s *traverseState) render(str *strings.Builder, ptrs int, v reflect.Value,
 			str.WriteString("{")
 
 			mkeys := v.MapKeys()
-			tryAndSortMapKeys(vt, mkeys)
+			tryAndSortMapKeys(vt, mkeys, opts)
+
+			elements := opts.redact.elements
+			opts = opts.withoutElementPolicies()
+			keyMode := ""
+			if mask {
+				keyMode = opts.redact.mapKeys
+			}
+			if elements != nil && elements.keys != "" {
+				keyMode = elements.keys
+			}
 
 			kt := vt.Key()
 			keyAnon := typeOfString.ConvertibleTo(kt) || typeOfInt.ConvertibleTo(kt) || typeOfUint.ConvertibleTo(kt) || typeOfFloat.ConvertibleTo(kt)
-			valAnon := vt.Name() == "" && isAnon(vt.Elem())
+			valAnon := vt.Name() == "" && isAnonType(vt.Elem())
 			for i, mk := range mkeys {
 				if i > 0 {
 					str.WriteString(", ")
 				}
+				if opts.canceled() {
+					str.WriteString(canceledPlaceholder)
+					break
+				}
 
-				s.render(str, 0, mk, keyAnon, false, opts)
-				str.WriteString(":")
-				s.render(str, 0, v.MapIndex(mk), valAnon, mask, opts)
+				if keyMode != "" && keyMode != KEEP {
+					key := s.redactKey(mk, keyAnon, keyMode, opts)
+					str.WriteString(key)
+					str.WriteString(":")
+					opts.enter("[" + key + "]")
+				} else {
+					s.render(str, 0, mk, keyAnon, false, opts)
+					str.WriteString(":")
+					opts.enterKey(mk)
+				}
+				if elements != nil {
+					s.renderElement(str, v.MapIndex(mk), valAnon, mask, elements.values, opts)
+				} else {
+					s.render(str, 0, v.MapIndex(mk), valAnon, mask, opts)
+				}
+				opts.leave()
 			}
 			str.WriteRune('}')
 		}
@@ -298,22 +411,9 @@ func (s *traverseState) render(str *strings.Builder, ptrs int, v reflect.Value,
 			fmt.Fprintf(str, "%q", value)
 		default:
 			valueStr := strings.Builder{}
-			switch vk {
-			case reflect.Bool:
-				fmt.Fprintf(&valueStr, "%v", v.Bool())
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				fmt.Fprintf(&valueStr, "%d", v.Int())
-
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-				fmt.Fprintf(&valueStr, "%d", v.Uint())
-
-			case reflect.Float32, reflect.Float64:
-				fmt.Fprintf(&valueStr, "%g", v.Float())
-			case reflect.Complex64, reflect.Complex128:
-				fmt.Fprintf(&valueStr, "%g", v.Complex())
-			}
+			writeScalar(&valueStr, v)
 			if mask {
-				opts.mask(str, valueStr.String())
+				opts.maskNumber(str, v, valueStr.String())
 			} else {
 				str.WriteString(valueStr.String())
 			}
@@ -325,6 +425,142 @@ func (s *traverseState) render(str *strings.Builder, ptrs int, v reflect.Value,
 	}
 }
 
+// isAnonType returns true if values of type t can be rendered without their
+// type when the enclosing type already conveys it.
+func isAnonType(t reflect.Type) bool {
+	if t.Name() != "" {
+		if _, ok := builtinTypeSet[t.Name()]; !ok {
+			return false
+		}
+	}
+	return t.Kind() != reflect.Interface
+}
+
+// renderFields writes the fields of the struct v, separated by commas.
+// written tells whether a field has already been written in the enclosing
+// braces, so that the fields of flattened embedded structs can be written
+// alongside their parent's.
+//
+// replace is the placeholder mode (REPLACE, HASH, TOKENIZE or ENCRYPT) of the
+// embedding field when the fields are promoted from a tagged embedded struct,
+// and hidden holds the names of the fields shadowing promoted ones.
+func (s *traverseState) renderFields(str *strings.Builder, v reflect.Value, mask bool, replace string, written *bool, hidden map[string]bool, opts *options) {
+	vt := v.Type()
+	opts = opts.forStruct(vt)
+	for i := 0; i < vt.NumField(); i++ {
+		f := vt.Field(i)
+		if hidden[f.Name] {
+			continue
+		}
+		if opts.isOmitted(f, v.Field(i)) {
+			opts.enter("." + opts.fieldName(f))
+			opts.recordRemoval(f, v.Field(i))
+			opts.leave()
+			continue
+		}
+		if opts.canceled() {
+			if *written {
+				str.WriteString(", ")
+			}
+			*written = true
+			str.WriteString(canceledPlaceholder)
+			return
+		}
+		opts.enter("." + opts.fieldName(f))
+		s.renderField(str, v, i, mask, replace, written, hidden, opts)
+		opts.leave()
+	}
+}
+
+// renderField writes the i-th field of the struct v. See renderFields.
+func (s *traverseState) renderField(str *strings.Builder, v reflect.Value, i int, mask bool, replace string, written *bool, hidden map[string]bool, opts *options) {
+	vt := v.Type()
+	f := vt.Field(i)
+	if opts.render.flattenEmbedded && f.Anonymous {
+		if ev, es, ok := s.embedded(v.Field(i)); ok {
+			mask, replace, opts := opts.forEmbedded(f, v.Field(i), mask, replace)
+			shadowing := make(map[string]bool, len(hidden)+vt.NumField())
+			for name := range hidden {
+				shadowing[name] = true
+			}
+			for j := 0; j < vt.NumField(); j++ {
+				shadowing[vt.Field(j).Name] = true
+			}
+			es.renderFields(str, ev, mask, replace, written, shadowing, opts)
+			return
+		}
+	}
+	if *written {
+		str.WriteString(", ")
+	}
+	*written = true
+
+	anon := vt.Name() == "" && isAnonType(f.Type)
+	if !anon {
+		str.WriteString(opts.fieldName(f))
+		str.WriteRune(':')
+	}
+	if replace != "" {
+		str.WriteString(opts.placeholder(replace, v.Field(i)))
+		return
+	}
+	if opts.redact.active && s.redactField(str, f, v.Field(i), anon, mask, opts) {
+		return
+	}
+	s.render(str, 0, v.Field(i), anon, mask, opts)
+}
+
+// forEmbedded returns the mask, placeholder mode and options with which the
+// fields promoted from the flattened embedded field f, of value v, are
+// rendered, applying the redaction rule of f as redactField would.
+func (o *options) forEmbedded(f reflect.StructField, v reflect.Value, mask bool, replace string) (bool, string, *options) {
+	if !o.redact.active || replace != "" {
+		return mask, replace, o
+	}
+	tag, param, _, ok := o.redactRule(f)
+	if o.redact.allowlist && o.kept(tag, o.currentPath()) {
+		return false, "", o.withoutAllowlist()
+	}
+	if !ok {
+		return mask, "", o
+	}
+	switch {
+	case isPlaceholderMode(tag):
+		o.record(f, v, tag)
+		return mask, tag, o
+	case tag == elementsMode:
+		o.record(f, v, param)
+		return mask, "", o.withElementPolicies(param)
+	case tag == GENERALIZE:
+		o.record(f, v, tag)
+		return true, "", o.withGeneralization(param)
+	case tag == MASK:
+		o.record(f, v, tag)
+		o = o.withoutGeneralization()
+		if param != "" {
+			o = o.withNumberMasking(param)
+		}
+		return true, "", o
+	}
+	return mask, "", o
+}
+
+// embedded returns the struct held by the embedded field v, dereferencing it
+// if it is a pointer, along with the state to traverse it. It returns false if
+// there is no struct to flatten: v is not a struct, is nil, or was already
+// traversed.
+func (s *traverseState) embedded(v reflect.Value) (reflect.Value, *traverseState, bool) {
+	switch {
+	case v.Kind() == reflect.Struct:
+		return v, s, true
+	case v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct:
+		if fs := s.forkFor(v.Pointer()); fs != nil {
+			return v.Elem(), fs, true
+		}
+	}
+	return reflect.Value{}, nil, false
+}
+
 func writeType(str *strings.Builder, ptrs int, t reflect.Type) {
 	parens := ptrs > 0
 	switch t.Kind() {
@@ -412,7 +648,10 @@ func (s sortableValueSlice) Swap(i, j int) {
 
 // cmpForType returns a cmpFn which sorts the data for some type t in the same
 // order that a go-native map key is compared for equality.
-func cmpForType(t reflect.Type) cmpFn {
+//
+// If opts asks for a stable map ordering, values which would otherwise be
+// ordered by memory address are ordered by their rendered representation.
+func cmpForType(t reflect.Type, opts *options) cmpFn {
 	switch t.Kind() {
 	case reflect.String:
 		return func(av, bv reflect.Value) int {
@@ -471,18 +710,26 @@ func cmpForType(t reflect.Type) cmpFn {
 		}
 
 	case reflect.Interface:
+		// Interface values are ordered by dynamic type name first, then by
+		// value when both share the same dynamic type.
 		return func(av, bv reflect.Value) int {
-			a, b := av.InterfaceData(), bv.InterfaceData()
-			if a[0] < b[0] {
+			switch {
+			case av.IsNil() && bv.IsNil():
+				return 0
+			case av.IsNil():
 				return -1
-			} else if a[0] > b[0] {
+			case bv.IsNil():
 				return 1
 			}
-			if a[1] < b[1] {
+			a, b := av.Elem(), bv.Elem()
+			if at, bt := a.Type().String(), b.Type().String(); at < bt {
 				return -1
-			} else if a[1] > b[1] {
+			} else if at > bt {
 				return 1
 			}
+			if cmp := cmpForType(a.Type(), opts); cmp != nil {
+				return cmp(a, b)
+			}
 			return 0
 		}
 
@@ -503,6 +750,14 @@ func cmpForType(t reflect.Type) cmpFn {
 		}
 
 	case reflect.Ptr, reflect.Chan:
+		if opts != nil && opts.render.stableMapOrdering {
+			// Compare the clear renderings: redacted ones may be equal for
+			// distinct keys, and must not fire the redaction hooks.
+			clear := opts.clear()
+			return func(av, bv reflect.Value) int {
+				return strings.Compare(clear.renderString(av, false), clear.renderString(bv, false))
+			}
+		}
 		return func(av, bv reflect.Value) int {
 			a, b := av.Pointer(), bv.Pointer()
 			if a < b {
@@ -516,7 +771,7 @@ func cmpForType(t reflect.Type) cmpFn {
 	case reflect.Struct:
 		cmpLst := make([]cmpFn, t.NumField())
 		for i := range cmpLst {
-			cmpLst[i] = cmpForType(t.Field(i).Type)
+			cmpLst[i] = cmpForType(t.Field(i).Type, opts)
 		}
 		return func(a, b reflect.Value) int {
 			for i, cmp := range cmpLst {
@@ -531,49 +786,103 @@ func cmpForType(t reflect.Type) cmpFn {
 	return nil
 }
 
-func tryAndSortMapKeys(mt reflect.Type, k []reflect.Value) {
-	if cmp := cmpForType(mt.Key()); cmp != nil {
+func tryAndSortMapKeys(mt reflect.Type, k []reflect.Value, opts *options) {
+	if cmp := cmpForType(mt.Key(), opts); cmp != nil {
 		sort.Sort(sortableValueSlice{cmp, k})
 	}
 }
 
-func (s *traverseState) redactField(str *strings.Builder, vt reflect.Type, v reflect.Value, i int, anon bool, mask bool, opts *options) bool {
-	tag, ok := vt.Field(i).Tag.Lookup(opts.redact.tag)
+// redactField writes the value of the struct field f if it must be redacted,
+// and returns true if it did. Removed fields are skipped beforehand by
+// isOmitted.
+func (s *traverseState) redactField(str *strings.Builder, f reflect.StructField, v reflect.Value, anon bool, mask bool, opts *options) bool {
+	tag, param, _, ok := opts.redactRule(f)
+	if opts.redact.allowlist && opts.kept(tag, opts.currentPath()) {
+		s.render(str, 0, v, anon, false, opts.withoutAllowlist())
+		return true
+	}
 	if !ok {
+		if mask {
+			opts.recordAllowlist(f, v)
+		}
 		return false
 	}
-	switch tag {
-	case REMOVE:
-		// no field, no value
+	switch {
+	case isPlaceholderMode(tag):
+		opts.record(f, v, tag)
+		str.WriteString(opts.placeholder(tag, v))
 		return true
-	default:
-		// write field
-		if i > 0 && !opts.isRemoved(vt.Field(i-1)) {
-			str.WriteString(", ")
-		}
-		if !anon {
-			str.WriteString(vt.Field(i).Name)
-			str.WriteRune(':')
-		}
-		switch {
-		case tag == REPLACE:
-			str.WriteRune('<')
-			str.WriteString(opts.redact.replacementPlaceholder)
-			str.WriteRune('>')
-			return true
-		case tag == MASK || mask:
-			s.render(str, 0, v.Field(i), anon, true, opts)
-			return true
+	case tag == elementsMode:
+		opts.record(f, v, param)
+		s.render(str, 0, v, anon, mask, opts.withElementPolicies(param))
+		return true
+	case tag == GENERALIZE:
+		opts.record(f, v, tag)
+		s.render(str, 0, v, anon, true, opts.withGeneralization(param))
+		return true
+	case tag == MASK || mask:
+		if tag == MASK {
+			opts.record(f, v, tag)
+			opts = opts.withoutGeneralization()
+			if param != "" {
+				opts = opts.withNumberMasking(param)
+			}
+		} else {
+			opts.recordAllowlist(f, v)
 		}
+		s.render(str, 0, v, anon, true, opts)
+		return true
 	}
 	return false
 }
 
+// writeScalar writes the representation of the bool or number v.
+func writeScalar(str *strings.Builder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		fmt.Fprintf(str, "%v", v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fmt.Fprintf(str, "%d", v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		fmt.Fprintf(str, "%d", v.Uint())
+
+	case reflect.Float32, reflect.Float64:
+		fmt.Fprintf(str, "%g", v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		fmt.Fprintf(str, "%g", v.Complex())
+	}
+}
+
+// redactKey returns the map key k redacted with mode, see WithMapKeys.
+func (s *traverseState) redactKey(k reflect.Value, anon bool, mode string, opts *options) string {
+	str := strings.Builder{}
+	s.renderElement(&str, k, anon, false, mode, opts)
+	return str.String()
+}
+
+// renderString renders v on its own, as it would be rendered as a member of
+// another value.
+func (o *options) renderString(v reflect.Value, mask bool) string {
+	str := strings.Builder{}
+	s := (*traverseState)(nil)
+	s.render(&str, 0, v, false, mask, o)
+	return str.String()
+}
+
 func (o *options) mask(str *strings.Builder, value string) {
 	if !o.redact.active {
 		str.WriteString(value)
 		return
 	}
+	if o.redact.generalizing {
+		o.generalizeString(str, value)
+		return
+	}
+	if o.redact.maskedClasses != nil || o.redact.maskingDigit != 0 {
+		o.maskRunes(str, value)
+		return
+	}
 	// whole string
 	if o.redact.maskingLength < 0 || o.redact.maskingLength >= len(value) {
 		str.WriteString(strings.Repeat(string(o.redact.maskingChar), len(value)))
@@ -590,8 +899,239 @@ func (o *options) mask(str *strings.Builder, value string) {
 	str.WriteString(value[o.redact.maskingLength:])
 }
 
+// maskRunes masks value rune by rune, only masking the runes of the masked
+// classes if any, and substituting digits by the masking digit if any.
+func (o *options) maskRunes(str *strings.Builder, value string) {
+	maskable := func(r rune) bool {
+		return o.redact.maskedClasses == nil || unicode.IsOneOf(o.redact.maskedClasses, r)
+	}
+	n := 0
+	for _, r := range value {
+		if maskable(r) {
+			n++
+		}
+	}
+	// the masked runes are the ones from first to last, counting maskable
+	// runes only
+	first, last := 0, n
+	switch length := o.redact.maskingLength; {
+	case length < 0 || length >= n:
+	case o.redact.maskingReverse:
+		first = n - length
+	default:
+		last = length
+	}
+	i := 0
+	for _, r := range value {
+		if !maskable(r) {
+			str.WriteRune(r)
+			continue
+		}
+		switch {
+		case i < first || i >= last:
+			str.WriteRune(r)
+		case o.redact.maskingDigit != 0 && unicode.IsDigit(r):
+			str.WriteRune(o.redact.maskingDigit)
+		default:
+			str.WriteRune(o.redact.maskingChar)
+		}
+		i++
+	}
+}
+
+// isOmitted returns true if the struct field f, of value v, must not be
+// rendered at all.
+func (o *options) isOmitted(f reflect.StructField, v reflect.Value) bool {
+	if o.redact.active && o.isRemoved(f) {
+		return true
+	}
+	if f.PkgPath != "" {
+		switch {
+		case o.render.unexportedFields == HideUnexported:
+			return true
+		case o.render.unexportedFields == RenderOnlyUnexported && o.redact.active:
+			return true
+		}
+	}
+	if o.render.fieldNameTag != "" {
+		tag := f.Tag.Get(o.render.fieldNameTag)
+		if tag == "-" {
+			return true
+		}
+		_, opts := parseNameTag(tag)
+		if o.render.omitEmpty && hasTagOption(opts, "omitempty") && isEmptyValue(v) {
+			return true
+		}
+	}
+	switch {
+	case o.render.omitZero && v.IsZero():
+		return true
+	case o.render.omitNil && isNil(v):
+		return true
+	case o.render.omitEmptyCollections && isEmptyCollection(v):
+		return true
+	}
+	return false
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice, reflect.UnsafePointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+func isEmptyCollection(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// fieldName returns the label of the struct field f.
+func (o *options) fieldName(f reflect.StructField) string {
+	if o.render.fieldNameTag != "" {
+		if name, _ := parseNameTag(f.Tag.Get(o.render.fieldNameTag)); name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// parseNameTag splits a tag such as `json:"name,omitempty"` into its name and
+// its options.
+func parseNameTag(tag string) (name string, opts string) {
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+func hasTagOption(opts string, option string) bool {
+	for opts != "" {
+		var o string
+		o, opts = parseNameTag(opts)
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyValue reports whether v is empty, as understood by the "omitempty"
+// option of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isPlaceholderMode returns true if the redact mode writes a placeholder
+// instead of the value, see placeholder.
+func isPlaceholderMode(mode string) bool {
+	switch mode {
+	case REPLACE, HASH, TOKENIZE, ENCRYPT:
+		return true
+	}
+	return false
+}
+
+// placeholder returns what is written instead of v when redacted with the
+// REPLACE, HASH, TOKENIZE or ENCRYPT mode.
+func (o *options) placeholder(mode string, v reflect.Value) string {
+	switch {
+	case mode == HASH:
+		return o.hash(v)
+	case mode == TOKENIZE && o.tokens != nil:
+		return o.tokenize(v)
+	case mode == ENCRYPT && o.redact.keys != nil:
+		return o.encrypt(v)
+	}
+	return "<" + o.redact.replacementPlaceholder + ">"
+}
+
+// redactTag returns the redact mode applying to the struct field f, and
+// whether there is one.
+//
+// The tag holds either a mode applying to all audiences, or a comma
+// separated list of audience=mode policies, where the "default" audience
+// applies to the audiences which are not listed, REPLACE applying without
+// default. Modes may be followed by a parameter in parentheses, e.g.
+// "MASK(ROUND=10)".
+func (o *options) redactTag(f reflect.StructField) (string, bool) {
+	mode, _, _, ok := o.redactRule(f)
+	return mode, ok
+}
+
+// redactRule returns the redact mode applying to the struct field f, its
+// parameter, the source of the rule, and whether there is one.
+func (o *options) redactRule(f reflect.StructField) (mode string, param string, source string, ok bool) {
+	if tag, ok := f.Tag.Lookup(o.redact.tag); ok {
+		if isElementPolicies(tag) {
+			return elementsMode, tag, SourceTag, true
+		}
+		if i := strings.IndexAny(tag, "=("); i < 0 || tag[i] == '(' {
+			mode, param := splitModeParam(tag)
+			return mode, param, SourceTag, true
+		}
+		mode, param := splitModeParam(o.audienceMode(tag))
+		return mode, param, SourceTag, true
+	}
+	if o.redact.structPolicy != "" {
+		mode, param := splitModeParam(o.redact.structPolicy)
+		return mode, param, SourcePolicy, true
+	}
+	if o.render.unexportedFields == ReplaceUnexported && f.PkgPath != "" {
+		return REPLACE, "", SourceUnexported, true
+	}
+	return "", "", "", false
+}
+
+// splitModeParam splits a policy such as "MASK(ROUND=10)" into its mode and
+// its parameter.
+func splitModeParam(policy string) (mode string, param string) {
+	if i := strings.IndexByte(policy, '('); i >= 0 && strings.HasSuffix(policy, ")") {
+		return policy[:i], policy[i+1 : len(policy)-1]
+	}
+	return policy, ""
+}
+
+// audienceMode returns the mode applying to the current audience in the list
+// of policies. Audiences which are neither listed nor covered by a default
+// policy fall back to REPLACE.
+func (o *options) audienceMode(policies string) string {
+	mode := REPLACE
+	for _, policy := range strings.Split(policies, ",") {
+		audience, m, ok := strings.Cut(strings.TrimSpace(policy), "=")
+		if !ok {
+			continue
+		}
+		switch {
+		case audience == o.redact.audience:
+			return m
+		case audience == DefaultAudience:
+			mode = m
+		}
+	}
+	return mode
+}
+
 func (o *options) isRemoved(vt reflect.StructField) bool {
-	tag, ok := vt.Tag.Lookup(o.redact.tag)
+	tag, ok := o.redactTag(vt)
 	if !ok {
 		return false
 	}
@@ -601,7 +1141,7 @@ func (o *options) isRemoved(vt reflect.StructField) bool {
 	return false
 }
 
-func (o *options) callRegisteredTypeFormatter(str *strings.Builder, ptrs int, vt reflect.Type, v reflect.Value, implicit bool) (formatted bool) {
+func (o *options) callRegisteredTypeFormatter(str *strings.Builder, ptrs int, vt reflect.Type, v reflect.Value, implicit bool, mask bool) (formatted bool) {
 	if typeFormatter, ok := o.render.typeFormatters[vt.String()]; ok {
 		// register a recover to avoid panicking on user provided type formatter
 		defer func() {
@@ -610,6 +1150,9 @@ func (o *options) callRegisteredTypeFormatter(str *strings.Builder, ptrs int, vt
 			}
 		}()
 		formattedType := typeFormatter(v.Interface())
+		if mask {
+			formattedType = o.maskString(formattedType)
+		}
 		if !implicit {
 			writeType(str, ptrs, vt)
 		}
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"testing"
 	"time"
+	"unicode"
 )
 
 func init() {
@@ -27,6 +28,9 @@ func init() {
 	}
 }
 
+// testHashKey is the key of the HASH redact mode in tests.
+var testHashKey = []byte("0123456789abcdef")
+
 func assertRendersLike(t *testing.T, name string, v interface{}, exp string) {
 	act := Render(v)
 	if act != exp {
@@ -183,7 +187,7 @@ func TestRenderImplicitType(t *testing.T) {
 	}
 }
 
-func ExampleInReadme() {
+func Example_inReadme() {
 	type customType int
 	type testStruct struct {
 		S string
@@ -264,6 +268,10 @@ func TestMapSortRendering(t *testing.T) {
 			map[interface{}]struct{}{1: {}, 2: {}, 3: {}, "foo": {}},
 			`map[interface{}]struct {}{1:{}, 2:{}, 3:{}, "foo":{}}`,
 		},
+		{
+			map[interface{}]struct{}{"b": {}, 2: {}, nil: {}, "a": {}, 1.5: {}, 1: {}},
+			`map[interface{}]struct {}{interface{}(nil):{}, 1.5:{}, 1:{}, 2:{}, "a":{}, "b":{}}`,
+		},
 		{
 			map[complex64]struct{}{1 + 2i: {}, 2 + 1i: {}, 3 + 1i: {}, 1 + 3i: {}},
 			"map[complex64]struct {}{(1+2i):{}, (1+3i):{}, (2+1i):{}, (3+1i):{}}",
@@ -386,12 +394,141 @@ func TestRedactRemove(t *testing.T) {
 			a int `redact:"REMOVE"`
 			b string
 		}{123456, "foo"}, `struct { a int "redact:\"REMOVE\""; b string }{"foo"}`},
+		{struct {
+			A string
+			B int `redact:"REMOVE"`
+			C string
+		}{"foo", 123456, "bar"}, `struct { A string; B int "redact:\"REMOVE\""; C string }{"foo", "bar"}`},
 		{[]interface{}{nil, 1, 2, testStruct{Name: "foo", m: "bar"}}, `[]interface{}{interface{}(nil), 1, 2, render.testStruct{Test:(*render.testStruct)(nil)}}`},
 	} {
 		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), tc.a, tc.s)
 	}
 }
 
+func TestFormatPreservingMask(t *testing.T) {
+	t.Parallel()
+
+	type contact struct {
+		Phone string `redact:"MASK"`
+		Date  string `redact:"MASK"`
+		ID    int    `redact:"MASK"`
+	}
+	c := contact{Phone: "+1 (555) 123-4567", Date: "2024-05-01", ID: -123456}
+
+	for i, tc := range []struct {
+		opts []MarshallerOption
+		s    string
+	}{
+		{[]MarshallerOption{WithFormatPreservingMask()},
+			`render.contact{Phone:"+# (###) 123-4567", Date:"####-05-01", ID:-####56}`},
+		{[]MarshallerOption{WithFormatPreservingMask(), WithMaskingLength(-1)},
+			`render.contact{Phone:"+# (###) ###-####", Date:"####-##-##", ID:-######}`},
+		{[]MarshallerOption{WithFormatPreservingMask(unicode.Digit), WithMaskingLength(4), WithMaskingReverse()},
+			`render.contact{Phone:"+1 (555) 123-####", Date:"2024-##-##", ID:-12####}`},
+		{[]MarshallerOption{WithDigitSubstitution('0')},
+			`render.contact{Phone:"#0##555) 123-4567", Date:"0000-05-01", ID:#000456}`},
+		{[]MarshallerOption{WithFormatPreservingMask(), WithDigitSubstitution('9'), WithMaskingLength(-1)},
+			`render.contact{Phone:"+9 (999) 999-9999", Date:"9999-99-99", ID:-999999}`},
+		{[]MarshallerOption{WithFormatPreservingMask(unicode.Letter), WithMaskingLength(-1)},
+			`render.contact{Phone:"+1 (555) 123-4567", Date:"2024-05-01", ID:-123456}`},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), c, tc.s, tc.opts...)
+	}
+
+	if _, err := NewMarshaller(WithDigitSubstitution('#')); err == nil {
+		t.Errorf("Expected an error on a non digit substitution")
+	}
+}
+
+func TestNumberMasking(t *testing.T) {
+	t.Parallel()
+
+	type person struct {
+		Age     int         `redact:"MASK(ROUND=10)"`
+		Salary  float64     `redact:"MASK(ROUND)"`
+		Debt    int64       `redact:"MASK(ROUND)"`
+		Score   uint8       `redact:"MASK(ZERO)"`
+		Admin   bool        `redact:"MASK(ZERO)"`
+		Signal  complex64   `redact:"MASK(ROUND=0.5)"`
+		Count   int         `redact:"MASK"`
+		Balance float32     `redact:"support=MASK(ROUND=100),default=REPLACE"`
+		Misc    []int       `redact:"MASK(ROUND=5)"`
+		I       interface{} `redact:"MASK(ZERO)"`
+	}
+	p := person{Age: 37, Salary: 54321.5, Debt: -4242, Score: 87, Admin: true, Signal: complex(1.7, -0.2), Count: 123456, Balance: 1234.5, Misc: []int{1, 7, -3}, I: 42}
+
+	assertRedactsLike(t, "per field", p,
+		`render.person{Age:30, Salary:10000, Debt:-1000, Score:0, Admin:false, Signal:(1.5-0.5i), Count:####56, Balance:<redacted>, Misc:[]int{0, 5, -5}, I:0}`)
+	assertRedactsLike(t, "marshaller wide", struct {
+		A int   `redact:"MASK"`
+		B uint  `redact:"MASK"`
+		C []int `redact:"MASK"`
+		D int   `redact:"MASK(STRING)"`
+	}{1234, 99, []int{-15, 0}, 1234},
+		`struct { A int "redact:\"MASK\""; B uint "redact:\"MASK\""; C []int "redact:\"MASK\""; D int "redact:\"MASK(STRING)\"" }{1000, 10, {-10, 0}, "####"}`,
+		WithNumberMasking(MaskNumberRound))
+
+	m, err := NewMarshaller()
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+	if act, exp := m.RedactFor("support", p), `Balance:1200,`; !strings.Contains(act, exp) {
+		t.Errorf("Expected %s in %s", exp, act)
+	}
+
+	for _, strategy := range []string{"ROUND=0", "ROUND=-1", "ROUND=x", "ZERO=1", "UNKNOWN"} {
+		if _, err := NewMarshaller(WithNumberMasking(strategy)); err == nil {
+			t.Errorf("Expected an error on the number masking %q", strategy)
+		}
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	t.Parallel()
+
+	type profile struct {
+		Token string `redact:"REPLACE"`
+	}
+	type directory struct {
+		Profiles map[string]profile `redact:"MASK"`
+		Counts   map[string]int
+	}
+	d := directory{
+		Profiles: map[string]profile{"bob@example.com": {"b"}, "alice@example.com": {"a"}},
+		Counts:   map[string]int{"bob": 1},
+	}
+
+	for i, tc := range []struct {
+		opts []MarshallerOption
+		s    string
+	}{
+		{nil, `render.directory{Profiles:map[string]render.profile{"alice@example.com":render.profile{Token:<redacted>}, "bob@example.com":render.profile{Token:<redacted>}}, Counts:map[string]int{"bob":1}}`},
+		{[]MarshallerOption{WithMapKeys(MASK)}, `render.directory{Profiles:map[string]render.profile{"####e@example.com":render.profile{Token:<redacted>}, "####example.com":render.profile{Token:<redacted>}}, Counts:map[string]int{"bob":1}}`},
+		{[]MarshallerOption{WithMapKeys(REPLACE)}, `render.directory{Profiles:map[string]render.profile{<redacted>:render.profile{Token:<redacted>}, <redacted>:render.profile{Token:<redacted>}}, Counts:map[string]int{"bob":1}}`},
+		{[]MarshallerOption{WithMapKeys(HASH), WithHashKey(testHashKey)}, `render.directory{Profiles:map[string]render.profile{<hmac:a7de83b39f8c4ee8>:render.profile{Token:<redacted>}, <hmac:b70c3ba10e84fbd2>:render.profile{Token:<redacted>}}, Counts:map[string]int{"bob":1}}`},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), d, tc.s, tc.opts...)
+	}
+
+	m, err := NewMarshaller(WithMapKeys(REPLACE))
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+	_, report := m.RedactWithReport(d)
+	for _, r := range report.Redactions {
+		if strings.Contains(r.Path, "example.com") {
+			t.Errorf("Map key leaked in the report path %s", r.Path)
+		}
+	}
+	if diff := m.Diff(d, directory{Profiles: map[string]profile{"bob@example.com": {"b"}}}); strings.Contains(diff, "alice") {
+		t.Errorf("Map key leaked in the diff:\n%s", diff)
+	}
+
+	if _, err := NewMarshaller(WithMapKeys(REMOVE)); err == nil {
+		t.Errorf("Expected an error on the REMOVE map keys mode")
+	}
+}
+
 func TestOptions(t *testing.T) {
 	t.Parallel()
 
@@ -467,3 +604,253 @@ func TestRegisteredTypes(t *testing.T) {
 		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), tc.a, tc.s, opts...)
 	}
 }
+
+func TestMarshallerRedactThenRender(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Name string `redact:"REPLACE"`
+	}
+
+	m, err := NewMarshaller()
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+	m.Redact(testStruct{Name: "foo"})
+	if act, exp := m.Render(testStruct{Name: "foo"}), `render.testStruct{Name:"foo"}`; act != exp {
+		t.Errorf("Render after Redact did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+}
+
+func TestFieldNameTag(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		UserID   int    `json:"user_id"`
+		Dash     string `json:"-,"`
+		Password string `json:"password,omitempty" redact:"REPLACE"`
+		Untagged bool
+		Name     string `json:",omitempty"`
+		Email    string `json:"email,omitempty"`
+		Ignored  string `json:"-"`
+	}
+
+	v := testStruct{UserID: 42, Ignored: "foo", Dash: "bar", Password: "hunter2"}
+	for i, tc := range []struct {
+		opts []MarshallerOption
+		s    string
+	}{
+		{[]MarshallerOption{WithFieldNameTag("json")},
+			`render.testStruct{user_id:42, -:"bar", password:<redacted>, Untagged:false, Name:"", email:""}`},
+		{[]MarshallerOption{WithFieldNameTag("json"), WithOmitEmpty()},
+			`render.testStruct{user_id:42, -:"bar", password:<redacted>, Untagged:false}`},
+		{[]MarshallerOption{WithFieldNameTag("yaml")},
+			`render.testStruct{UserID:42, Dash:"bar", Password:<redacted>, Untagged:false, Name:"", Email:"", Ignored:"foo"}`},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), v, tc.s, tc.opts...)
+	}
+
+	if _, err := NewMarshaller(WithFieldNameTag("not a tag")); err == nil {
+		t.Errorf("Expected an error for an invalid field name tag")
+	}
+}
+
+func TestOmitFields(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Name    string
+		Port    int
+		Secret  string `redact:"REMOVE"`
+		Ptr     *int
+		Any     interface{}
+		List    []string
+		Empty   []string
+		Map     map[string]int
+		Enabled bool
+	}
+
+	v := config{Name: "svc", Secret: "s3cr3t", Empty: []string{}, Map: map[string]int{}, Enabled: true}
+	for i, tc := range []struct {
+		opts []MarshallerOption
+		s    string
+	}{
+		{[]MarshallerOption{WithOmitZeroFields()},
+			`render.config{Name:"svc", Empty:[]string{}, Map:map[string]int{}, Enabled:true}`},
+		{[]MarshallerOption{WithOmitNilFields()},
+			`render.config{Name:"svc", Port:0, Empty:[]string{}, Map:map[string]int{}, Enabled:true}`},
+		{[]MarshallerOption{WithOmitEmptyCollections()},
+			`render.config{Name:"svc", Port:0, Ptr:(*int)(nil), Any:interface{}(nil), Enabled:true}`},
+		{[]MarshallerOption{WithOmitZeroFields(), WithOmitEmptyCollections()},
+			`render.config{Name:"svc", Enabled:true}`},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), v, tc.s, tc.opts...)
+	}
+
+	assertRedactsLike(t, "All omitted", config{}, `render.config{}`, WithOmitZeroFields())
+}
+
+type Credentials struct {
+	User     string
+	Password string `redact:"REPLACE"`
+}
+
+type Base struct {
+	ID   int
+	Name string
+}
+
+func TestFlattenEmbedded(t *testing.T) {
+	t.Parallel()
+
+	type plain struct {
+		Base
+		Name string
+	}
+	type masked struct {
+		Credentials `redact:"MASK"`
+		Host        string
+	}
+	type replaced struct {
+		*Credentials `redact:"REPLACE"`
+		Host         string
+	}
+	type removed struct {
+		Credentials `redact:"REMOVE"`
+		Host        string
+	}
+	type hashed struct {
+		Credentials `redact:"HASH"`
+		Host        string
+	}
+	type tokenized struct {
+		Credentials `redact:"TOKENIZE"`
+		Host        string
+	}
+	type encrypted struct {
+		Credentials `redact:"ENCRYPT"`
+		Host        string
+	}
+	type generalized struct {
+		Credentials `redact:"GENERALIZE(prefix=2)"`
+		Host        string
+	}
+	type labels struct {
+		Labels map[string]string
+	}
+	type elements struct {
+		labels `redact:"keys=REPLACE,values=REPLACE"`
+		Host   string
+	}
+	type recursive struct {
+		*recursive
+		Name string
+	}
+	rec := &recursive{Name: "loop"}
+	rec.recursive = rec
+
+	creds := Credentials{User: "admin", Password: "hunter2"}
+	for i, tc := range []struct {
+		a    interface{}
+		s    string
+		opts []MarshallerOption
+	}{
+		{plain{Base{1, "base"}, "outer"}, `render.plain{ID:1, Name:"outer"}`, nil},
+		{masked{creds, "db"}, `render.masked{User:"####n", Password:<redacted>, Host:"db"}`, nil},
+		{replaced{&creds, "db"}, `render.replaced{User:<redacted>, Password:<redacted>, Host:"db"}`, nil},
+		{replaced{nil, "db"}, `render.replaced{Credentials:<redacted>, Host:"db"}`, nil},
+		{removed{creds, "db"}, `render.removed{Host:"db"}`, nil},
+		{hashed{creds, "db"}, `render.hashed{User:<hmac:3e1768cdfe446a57>, Password:<hmac:d6c860bad44302c1>, Host:"db"}`, []MarshallerOption{WithHashKey(testHashKey)}},
+		// without vault nor encryption key, the promoted fields are replaced
+		{tokenized{creds, "db"}, `render.tokenized{User:<redacted>, Password:<redacted>, Host:"db"}`, nil},
+		{encrypted{creds, "db"}, `render.encrypted{User:<redacted>, Password:<redacted>, Host:"db"}`, nil},
+		{generalized{creds, "db"}, `render.generalized{User:"ad###", Password:<redacted>, Host:"db"}`, nil},
+		{elements{labels{map[string]string{"team": "core"}}, "db"}, `render.elements{Labels:map[string]string{<redacted>:<redacted>}, Host:"db"}`, nil},
+		{rec, `(*render.recursive){recursive:<recursive(*render.recursive)>, Name:"loop"}`, nil},
+	} {
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), tc.a, tc.s, append([]MarshallerOption{WithFlattenEmbedded()}, tc.opts...)...)
+	}
+}
+
+func TestUnexportedFields(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Name   string
+		token  string
+		secret string `redact:"MASK"`
+	}
+
+	v := testStruct{Name: "foo", token: "abcdef", secret: "hunter2"}
+	w := testStruct{Name: "bar", token: "ghijkl", secret: "hunter3"}
+	for i, tc := range []struct {
+		visibility string
+		render     string
+		redact     string
+		diff       string
+	}{
+		{ShowUnexported,
+			`render.testStruct{Name:"foo", token:"abcdef", secret:"hunter2"}`,
+			`render.testStruct{Name:"foo", token:"abcdef", secret:"####er2"}`,
+			"~ .Name: \"foo\" -> \"bar\"\n~ .token: \"abcdef\" -> \"ghijkl\"\n~ .secret: \"####er2\" -> \"####er3\""},
+		{HideUnexported,
+			`render.testStruct{Name:"foo"}`,
+			`render.testStruct{Name:"foo"}`,
+			`~ .Name: "foo" -> "bar"`},
+		{RenderOnlyUnexported,
+			`render.testStruct{Name:"foo", token:"abcdef", secret:"hunter2"}`,
+			`render.testStruct{Name:"foo"}`,
+			`~ .Name: "foo" -> "bar"`},
+		{ReplaceUnexported,
+			`render.testStruct{Name:"foo", token:"abcdef", secret:"hunter2"}`,
+			`render.testStruct{Name:"foo", token:<redacted>, secret:"####er2"}`,
+			"~ .Name: \"foo\" -> \"bar\"\n~ .token: <redacted> -> <redacted>\n~ .secret: \"####er2\" -> \"####er3\""},
+	} {
+		m, err := NewMarshaller(WithUnexportedFields(tc.visibility))
+		if err != nil {
+			t.Fatalf("Error on creating marshaller: %v", err)
+		}
+		if act := m.Render(v); act != tc.render {
+			t.Errorf("Input #%d: Render did not match expectations:\nExpected: %s\nActual  : %s\n", i, tc.render, act)
+		}
+		assertRedactsLike(t, fmt.Sprintf("Input #%d", i), v, tc.redact, WithUnexportedFields(tc.visibility))
+		if act := m.Diff(v, w); act != tc.diff {
+			t.Errorf("Input #%d: Diff did not match expectations:\nExpected: %s\nActual  : %s\n", i, tc.diff, act)
+		}
+	}
+
+	if _, err := NewMarshaller(WithUnexportedFields("NOPE")); err == nil {
+		t.Errorf("Expected an error for an invalid visibility")
+	}
+}
+
+func TestRedactFor(t *testing.T) {
+	t.Parallel()
+
+	type card struct {
+		Holder string `redact:"MASK"`
+		Number string `redact:"support=MASK,audit=HASH,default=REMOVE"`
+		CVV    string `redact:"audit=REPLACE"`
+	}
+
+	m, err := NewMarshaller(WithHashKey(testHashKey))
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+
+	v := card{Holder: "alice", Number: "4242424242424242", CVV: "123"}
+	for i, tc := range []struct {
+		audience string
+		s        string
+	}{
+		{"support", `render.card{Holder:"####e", Number:"####424242424242", CVV:<redacted>}`},
+		{"audit", `render.card{Holder:"####e", Number:<hmac:ea1c2ce7b3aafceb>, CVV:<redacted>}`},
+		{"debug", `render.card{Holder:"####e", CVV:<redacted>}`},
+		{DefaultAudience, `render.card{Holder:"####e", CVV:<redacted>}`},
+	} {
+		if act := m.RedactFor(tc.audience, v); act != tc.s {
+			t.Errorf("Input #%d did not match expectations:\nExpected: %s\nActual  : %s\n", i, tc.s, act)
+		}
+	}
+	assertRedactsLike(t, "Redact", v, `render.card{Holder:"####e", CVV:<redacted>}`)
+}
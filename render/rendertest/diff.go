@@ -0,0 +1,165 @@
+package rendertest
+
+import (
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// Indent spreads a rendered value over several lines, one member per line,
+// so that two renderings can be compared line by line.
+func Indent(rendered string) string {
+	str := strings.Builder{}
+	depth, parens := 0, 0
+	newline := func() {
+		str.WriteRune('\n')
+		str.WriteString(strings.Repeat("  ", depth))
+	}
+	for i := 0; i < len(rendered); i++ {
+		c := rendered[i]
+		switch {
+		case c == '"':
+			end := skipQuoted(rendered, i)
+			str.WriteString(rendered[i:end])
+			i = end - 1
+		case c == '(':
+			parens++
+			str.WriteByte(c)
+		case c == ')':
+			parens--
+			str.WriteByte(c)
+		case parens > 0:
+			str.WriteByte(c)
+		case c == '{' && (strings.HasSuffix(rendered[:i], "struct ") || strings.HasSuffix(rendered[:i], "interface ")):
+			// Anonymous type definition, not a value.
+			end := skipBraces(rendered, i)
+			str.WriteString(rendered[i:end])
+			i = end - 1
+		case c == '{' && i+1 < len(rendered) && rendered[i+1] == '}':
+			str.WriteString("{}")
+			i++
+		case c == '{':
+			str.WriteByte(c)
+			depth++
+			newline()
+		case c == '}':
+			depth--
+			newline()
+			str.WriteByte(c)
+		case c == ',' && i+1 < len(rendered) && rendered[i+1] == ' ':
+			str.WriteByte(c)
+			newline()
+			i++
+		default:
+			str.WriteByte(c)
+		}
+	}
+	return str.String()
+}
+
+func skipQuoted(s string, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+func skipBraces(s string, i int) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '"':
+			j = skipQuoted(s, j) - 1
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+// LineDiff returns a line-level diff of want and got: removed lines are
+// prefixed by "-", added lines by "+" and unchanged lines by a space. Only the
+// changes and their surrounding lines are shown.
+func LineDiff(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, line{'+', b[j]})
+			j++
+		default:
+			lines = append(lines, line{'-', a[i]})
+			i++
+		}
+	}
+
+	// Only keep the lines close enough to a change.
+	keep := make([]bool, len(lines))
+	for k, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for c := k - diffContext; c <= k+diffContext; c++ {
+			if c >= 0 && c < len(lines) {
+				keep[c] = true
+			}
+		}
+	}
+	str := strings.Builder{}
+	skipped := false
+	for k, l := range lines {
+		if !keep[k] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			str.WriteString("  ...\n")
+		}
+		skipped = false
+		str.WriteByte(l.op)
+		str.WriteByte(' ')
+		str.WriteString(l.text)
+		str.WriteByte('\n')
+	}
+	return str.String()
+}
@@ -0,0 +1,110 @@
+// Package rendertest provides helpers to assert on the output of the render
+// package in tests, either inline or against golden files.
+//
+// Golden files live under the "testdata" directory of the package being
+// tested and are named after the test. Run the tests with the
+// -rendertest.update flag, or with the RENDERTEST_UPDATE environment variable
+// set to a non-empty value, to write the current outputs to the golden files.
+package rendertest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reno-xjb/go-render-redact/render"
+)
+
+// PointerPlaceholder is rendered instead of channel, function and unsafe
+// pointer addresses by the deterministic marshaller.
+const PointerPlaceholder = "PTR"
+
+// UpdateEnv is the environment variable which, when set to a non-empty value,
+// updates the golden files instead of comparing against them.
+const UpdateEnv = "RENDERTEST_UPDATE"
+
+// GoldenDir is the directory, relative to the package being tested, where
+// golden files are read and written.
+var GoldenDir = "testdata"
+
+var update = flag.Bool("rendertest.update", false, "update the render golden files")
+
+// NewMarshaller creates a deterministic Marshaller: pointers render as
+// PointerPlaceholder and map keys are ordered independently of memory
+// addresses. Additional options are applied afterwards.
+func NewMarshaller(opts ...render.MarshallerOption) (*render.Marshaller, error) {
+	return render.NewMarshaller(append([]render.MarshallerOption{
+		render.WithPointerPlaceholder(PointerPlaceholder),
+		render.WithStableMapOrdering(),
+	}, opts...)...)
+}
+
+func mustMarshaller(t testing.TB, opts []render.MarshallerOption) *render.Marshaller {
+	t.Helper()
+	m, err := NewMarshaller(opts...)
+	if err != nil {
+		t.Fatalf("rendertest: cannot create marshaller: %v", err)
+	}
+	return m
+}
+
+// AssertRenders reports an error if v does not render as want with a
+// deterministic marshaller. It returns true if the assertion succeeded.
+func AssertRenders(t testing.TB, v interface{}, want string, opts ...render.MarshallerOption) bool {
+	t.Helper()
+	return assertEqual(t, "Render", want, mustMarshaller(t, opts).Render(v))
+}
+
+// AssertRedacts reports an error if v does not redact as want with a
+// deterministic marshaller. It returns true if the assertion succeeded.
+func AssertRedacts(t testing.TB, v interface{}, want string, opts ...render.MarshallerOption) bool {
+	t.Helper()
+	return assertEqual(t, "Redact", want, mustMarshaller(t, opts).Redact(v))
+}
+
+// AssertRendersGolden compares the rendering of v with the golden file named
+// after the test.
+func AssertRendersGolden(t testing.TB, v interface{}, opts ...render.MarshallerOption) bool {
+	t.Helper()
+	return AssertGolden(t, t.Name(), mustMarshaller(t, opts).Render(v))
+}
+
+// AssertRedactsGolden compares the redaction of v with the golden file named
+// after the test.
+func AssertRedactsGolden(t testing.TB, v interface{}, opts ...render.MarshallerOption) bool {
+	t.Helper()
+	return AssertGolden(t, t.Name(), mustMarshaller(t, opts).Redact(v))
+}
+
+// AssertGolden compares got with the content of the golden file
+// GoldenDir/name.golden, or writes got to it when updating.
+func AssertGolden(t testing.TB, name string, got string) bool {
+	t.Helper()
+	path := filepath.Join(GoldenDir, filepath.FromSlash(name)+".golden")
+	if *update || os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("rendertest: cannot create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got+"\n"), 0644); err != nil {
+			t.Fatalf("rendertest: cannot write golden file: %v", err)
+		}
+		return true
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("rendertest: cannot read golden file (run with -rendertest.update or %s=1 to create it): %v", UpdateEnv, err)
+		return false
+	}
+	return assertEqual(t, path, strings.TrimSuffix(string(want), "\n"), got)
+}
+
+func assertEqual(t testing.TB, what, want, got string) bool {
+	t.Helper()
+	if want == got {
+		return true
+	}
+	t.Errorf("%s did not match expectations (-want +got):\n%s", what, LineDiff(Indent(want), Indent(got)))
+	return false
+}
@@ -0,0 +1,135 @@
+package rendertest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recorder is a testing.TB which records the reported errors.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+type testStruct struct {
+	Name   string `redact:"REPLACE"`
+	C      chan int
+	Ptrs   map[*int]string
+	Nested []int
+}
+
+func newTestStruct() testStruct {
+	one, two := 1, 2
+	return testStruct{
+		Name:   "foo",
+		C:      make(chan int),
+		Ptrs:   map[*int]string{&two: "two", &one: "one"},
+		Nested: []int{1, 2, 3},
+	}
+}
+
+func TestAssertRenders(t *testing.T) {
+	t.Parallel()
+
+	AssertRenders(t, newTestStruct(), `rendertest.testStruct{Name:"foo", C:(chan int)(PTR), `+
+		`Ptrs:map[*int]string{(*int)(1):"one", (*int)(2):"two"}, Nested:[]int{1, 2, 3}}`)
+	AssertRedacts(t, newTestStruct(), `rendertest.testStruct{Name:<redacted>, C:(chan int)(PTR), `+
+		`Ptrs:map[*int]string{(*int)(1):"one", (*int)(2):"two"}, Nested:[]int{1, 2, 3}}`)
+
+	r := &recorder{TB: t}
+	if AssertRenders(r, []int{1, 2, 3}, `[]int{1, 4, 3}`) {
+		t.Errorf("AssertRenders should have failed")
+	}
+	exp := "Render did not match expectations (-want +got):\n" +
+		"  []int{\n" +
+		"    1,\n" +
+		"-   4,\n" +
+		"+   2,\n" +
+		"    3\n" +
+		"  }\n"
+	if len(r.errors) != 1 || r.errors[0] != exp {
+		t.Errorf("Unexpected errors:\nExpected: %q\nActual  : %q", exp, r.errors)
+	}
+}
+
+func TestAssertRedactsPointerKeys(t *testing.T) {
+	t.Parallel()
+
+	type key struct {
+		Name string `redact:"REPLACE"`
+	}
+	m := map[*key]int{}
+	for i, name := range []string{"h", "c", "a", "f", "b", "g", "e", "d"} {
+		m[&key{name}] = i
+	}
+	// keys only differing by a redacted field are still ordered by their
+	// clear value
+	for i := 0; i < 10; i++ {
+		AssertRedacts(t, m, `map[*rendertest.key]int{(*rendertest.key){Name:<redacted>}:2, `+
+			`(*rendertest.key){Name:<redacted>}:4, (*rendertest.key){Name:<redacted>}:1, `+
+			`(*rendertest.key){Name:<redacted>}:7, (*rendertest.key){Name:<redacted>}:6, `+
+			`(*rendertest.key){Name:<redacted>}:3, (*rendertest.key){Name:<redacted>}:5, `+
+			`(*rendertest.key){Name:<redacted>}:0}`)
+	}
+}
+
+func TestIndent(t *testing.T) {
+	t.Parallel()
+
+	for i, tc := range []struct {
+		in, out string
+	}{
+		{`1`, `1`},
+		{`[]int{}`, `[]int{}`},
+		{`[]interface{}{interface{}(nil), "a, {b}"}`, "[]interface{}{\n  interface{}(nil),\n  \"a, {b}\"\n}"},
+		{`struct { a int; b string }{1, "x"}`, "struct { a int; b string }{\n  1,\n  \"x\"\n}"},
+		{`map[string][]int{"a":{1}}`, "map[string][]int{\n  \"a\":{\n    1\n  }\n}"},
+	} {
+		if act := Indent(tc.in); act != tc.out {
+			t.Errorf("Input #%d did not match expectations:\nExpected: %s\nActual  : %s\n", i, tc.out, act)
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	AssertRedactsGolden(t, newTestStruct())
+
+	r := &recorder{TB: t}
+	if AssertGolden(r, "missing", "value") {
+		t.Errorf("AssertGolden should have failed on a missing file")
+	}
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	dir, err := os.MkdirTemp("", "rendertest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(goldenDir string) { GoldenDir = goldenDir }(GoldenDir)
+	GoldenDir = dir
+	os.Setenv(UpdateEnv, "1")
+	defer os.Unsetenv(UpdateEnv)
+
+	AssertRendersGolden(t, newTestStruct())
+	content, err := os.ReadFile(filepath.Join(dir, "TestGoldenUpdate.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), `rendertest.testStruct{Name:"foo"`) {
+		t.Errorf("Unexpected golden file content: %s", content)
+	}
+}
@@ -0,0 +1,166 @@
+package render
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Redaction rule sources
+const (
+	// SourceTag is the source of rules given by a redact tag
+	SourceTag = "tag"
+	// SourceUnexported is the source of rules given by WithUnexportedFields
+	SourceUnexported = "unexported"
+	// SourcePolicy is the source of rules given by the policy of a type, see
+	// RedactPolicer and WithTypePolicy
+	SourcePolicy = "policy"
+	// SourceAllowlist is the source of the masking of the fields which are not
+	// allowed by WithAllowlist
+	SourceAllowlist = "allowlist"
+)
+
+// Redaction describes a redaction applied to a struct field. It never holds
+// the original value.
+type Redaction struct {
+	// Path is the path of the field from the redacted value, e.g.
+	// ".Users[2].Password". Map keys are written as "[*]", or as redacted
+	// when their map is masked with WithMapKeys, so that paths never hold
+	// original values.
+	Path string
+	// Type is the Go type of the field.
+	Type string
+	// Mode is the redact mode applied, e.g. MASK, or the element policies,
+	// e.g. "keys=KEEP,values=MASK".
+	Mode string
+	// Source is the source of the rule which applied, e.g. SourceTag.
+	Source string
+	// Length is the length of the original value: the number of bytes of a
+	// string, or of the representation of other values.
+	Length int
+}
+
+// Report lists the redactions applied by RedactWithReport, in the order they
+// were applied.
+type Report struct {
+	Redactions []Redaction
+}
+
+// RedactWithReport redacts v like Redact and also returns a report of what
+// was redacted, e.g. for security reviews.
+func (m *Marshaller) RedactWithReport(v interface{}) (string, Report) {
+	str := strings.Builder{}
+	s := (*traverseState)(nil)
+	opts := m.redactOptions()
+	opts.recorder = &recorder{report: &Report{}}
+	s.render(&str, 0, reflect.ValueOf(v), false, opts.redact.allowlist, opts)
+	redacted, _ := opts.flushString(str.String())
+	return redacted, *opts.recorder.report
+}
+
+// RedactWithReport redacts v and reports what was redacted. See
+// Marshaller.RedactWithReport for more details.
+func RedactWithReport(v interface{}) (string, Report) {
+	m := newDefaultMarshaller()
+	return m.RedactWithReport(v)
+}
+
+// recorder tracks the path of the value being traversed, and the redactions
+// applied along the way.
+type recorder struct {
+	// path is the path of the value being traversed, as matched by allowed
+	// paths.
+	path []string
+	// shown is the path as reported, map keys in clear being written "[*]".
+	shown  []string
+	report *Report
+}
+
+// enter appends segment to the current path.
+func (o *options) enter(segment string) {
+	o.enterAs(segment, segment)
+}
+
+// enterKey appends the map key k to the current path.
+func (o *options) enterKey(k reflect.Value) {
+	if o.recorder != nil {
+		o.enterAs("["+o.clear().renderString(k, false)+"]", "[*]")
+	}
+}
+
+// enterAs appends segment to the current path, reported as shown.
+func (o *options) enterAs(segment string, shown string) {
+	if o.recorder != nil {
+		o.recorder.path = append(o.recorder.path, segment)
+		o.recorder.shown = append(o.recorder.shown, shown)
+	}
+}
+
+// leave removes the last segment of the current path.
+func (o *options) leave() {
+	if o.recorder != nil {
+		o.recorder.path = o.recorder.path[:len(o.recorder.path)-1]
+		o.recorder.shown = o.recorder.shown[:len(o.recorder.shown)-1]
+	}
+}
+
+// clear returns a copy of the options rendering values without redaction nor
+// recording.
+func (o *options) clear() *options {
+	clear := *o
+	clear.redact.active = false
+	clear.recorder = nil
+	return &clear
+}
+
+// record records that the struct field f, of value v, at the current path has
+// been redacted with mode, by the rule returned by redactRule.
+func (o *options) record(f reflect.StructField, v reflect.Value, mode string) {
+	if o.recorder == nil {
+		return
+	}
+	_, _, source, _ := o.redactRule(f)
+	o.recordFrom(f, v, mode, source)
+}
+
+// recordFrom records that the struct field f, of value v, at the current path
+// has been redacted with mode, by a rule coming from source.
+func (o *options) recordFrom(f reflect.StructField, v reflect.Value, mode string, source string) {
+	r := Redaction{
+		Path:   displayPath(strings.Join(o.recorder.shown, "")),
+		Type:   f.Type.String(),
+		Mode:   mode,
+		Source: source,
+		Length: o.valueLength(v),
+	}
+	if o.recorder.report != nil {
+		o.recorder.report.Redactions = append(o.recorder.report.Redactions, r)
+	}
+	for _, hook := range o.redact.hooks {
+		hook(r)
+	}
+}
+
+// recordRemoval records the redaction of the struct field f if it has been
+// omitted because of a redaction rule.
+func (o *options) recordRemoval(f reflect.StructField, v reflect.Value) {
+	if o.recorder == nil || !o.redact.active {
+		return
+	}
+	switch {
+	case o.isRemoved(f):
+		o.record(f, v, REMOVE)
+	case f.PkgPath != "" && o.render.unexportedFields == RenderOnlyUnexported:
+		o.recordFrom(f, v, REMOVE, SourceUnexported)
+	}
+}
+
+// valueLength returns the length of v as reported in a Redaction.
+func (o *options) valueLength(v reflect.Value) int {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.String {
+		return len(v.String())
+	}
+	return len(o.clear().renderString(v, false))
+}
@@ -0,0 +1,54 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactWithReport(t *testing.T) {
+	t.Parallel()
+
+	type card struct {
+		Number string `redact:"MASK"`
+		CVV    int    `redact:"REMOVE"`
+	}
+	type user struct {
+		Name     string
+		Password string `redact:"REPLACE"`
+		Cards    []card
+		Tokens   map[string]interface{}
+		session  string
+	}
+
+	v := user{
+		Name:     "alice",
+		Password: "hunter2",
+		Cards:    []card{{Number: "4242424242424242", CVV: 123}},
+		Tokens:   map[string]interface{}{"api": &card{Number: "1234"}},
+		session:  "abcdef",
+	}
+	m, err := NewMarshaller(WithUnexportedFields(ReplaceUnexported))
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+
+	act, report := m.RedactWithReport(v)
+	if exp := m.Redact(v); act != exp {
+		t.Errorf("RedactWithReport did not render like Redact:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+	exp := []Redaction{
+		{Path: ".Password", Type: "string", Mode: REPLACE, Source: SourceTag, Length: 7},
+		{Path: ".Cards[0].Number", Type: "string", Mode: MASK, Source: SourceTag, Length: 16},
+		{Path: ".Cards[0].CVV", Type: "int", Mode: REMOVE, Source: SourceTag, Length: 3},
+		{Path: ".Tokens[*].Number", Type: "string", Mode: MASK, Source: SourceTag, Length: 4},
+		{Path: ".Tokens[*].CVV", Type: "int", Mode: REMOVE, Source: SourceTag, Length: 1},
+		{Path: ".session", Type: "string", Mode: REPLACE, Source: SourceUnexported, Length: 6},
+	}
+	if !reflect.DeepEqual(report.Redactions, exp) {
+		t.Errorf("Report did not match expectations:\nExpected: %+v\nActual  : %+v\n", exp, report.Redactions)
+	}
+
+	if _, report := RedactWithReport("foo"); len(report.Redactions) != 0 {
+		t.Errorf("Expected an empty report, got %+v", report.Redactions)
+	}
+}
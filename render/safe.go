@@ -0,0 +1,77 @@
+package render
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// SafeValue wraps a value so that formatting it with the fmt package redacts
+// it. See Safe. The zero SafeValue formats as a nil value.
+type SafeValue struct {
+	m *Marshaller
+	v interface{}
+}
+
+// Safe wraps v so that it is redacted when formatted with the fmt package
+// verbs %v, %+v, %#v, %s and %q, making it a drop-in replacement in existing
+// format strings:
+//
+//	fmt.Printf("request: %+v", render.Safe(req))
+//
+// Precision truncates the output to the given number of characters and width
+// pads it, as for strings.
+func Safe(v interface{}) SafeValue {
+	return newDefaultMarshaller().Safe(v)
+}
+
+// Safe wraps v so that it is redacted with the marshaller when formatted
+// with the fmt package. See the package level Safe function for more details.
+func (m *Marshaller) Safe(v interface{}) SafeValue {
+	return SafeValue{m: m, v: v}
+}
+
+// marshaller returns the marshaller of s, or a default one for the zero
+// SafeValue.
+func (s SafeValue) marshaller() *Marshaller {
+	if s.m == nil {
+		return newDefaultMarshaller()
+	}
+	return s.m
+}
+
+// String implements fmt.Stringer.
+func (s SafeValue) String() string {
+	return s.marshaller().Redact(s.v)
+}
+
+// Format implements fmt.Formatter.
+func (s SafeValue) Format(f fmt.State, verb rune) {
+	m := s.marshaller()
+	var out string
+	if verb == 'v' && f.Flag('#') && m.options.render.goSyntaxRender {
+		out = m.Render(s.v)
+	} else {
+		out = m.Redact(s.v)
+	}
+	if prec, ok := f.Precision(); ok && utf8.RuneCountInString(out) > prec {
+		out = string([]rune(out)[:prec])
+	}
+	switch verb {
+	case 'v', 's':
+	case 'q':
+		out = fmt.Sprintf("%q", out)
+	default:
+		fmt.Fprintf(f, "%%!%c(%s)", verb, out)
+		return
+	}
+	if width, ok := f.Width(); ok {
+		for pad := width - utf8.RuneCountInString(out); pad > 0; pad-- {
+			if f.Flag('-') {
+				out += " "
+			} else {
+				out = " " + out
+			}
+		}
+	}
+	fmt.Fprint(f, out)
+}
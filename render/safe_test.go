@@ -0,0 +1,46 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSafe(t *testing.T) {
+	t.Parallel()
+
+	type testStruct struct {
+		Name     string
+		Password string `redact:"REPLACE"`
+	}
+	v := testStruct{Name: "foo", Password: "hunter2"}
+
+	m, err := NewMarshaller(WithGoSyntaxRender())
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+
+	for i, tc := range []struct {
+		format string
+		a      interface{}
+		s      string
+	}{
+		{"%v", Safe(v), `render.testStruct{Name:"foo", Password:<redacted>}`},
+		{"%+v", Safe(v), `render.testStruct{Name:"foo", Password:<redacted>}`},
+		{"%#v", Safe(v), `render.testStruct{Name:"foo", Password:<redacted>}`},
+		{"%s", Safe(&v), `(*render.testStruct){Name:"foo", Password:<redacted>}`},
+		{"%#v", m.Safe(v), `render.testStruct{Name:"foo", Password:"hunter2"}`},
+		{"%v", m.Safe(v), `render.testStruct{Name:"foo", Password:<redacted>}`},
+		{"%.17v", Safe(v), `render.testStruct`},
+		{"[%8.3s]", Safe("hello"), `[     "he]`},
+		{"[%-8.3s]", Safe("hello"), `["he     ]`},
+		{"%q", Safe(1), `"1"`},
+		{"%d", Safe(1), `%!d(1)`},
+		{"%v", Safe(nil), `nil`},
+		{"%v", SafeValue{}, `nil`},
+		{"%s", &SafeValue{}, `nil`},
+	} {
+		if act := fmt.Sprintf(tc.format, tc.a); act != tc.s {
+			t.Errorf("Input #%d did not match expectations:\nExpected: %s\nActual  : %s\n", i, tc.s, act)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package slogredact bridges the render package and log/slog: values logged
+// through it are redacted according to their redact tags.
+package slogredact
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+
+	"github.com/reno-xjb/go-render-redact/render"
+)
+
+// Valuer implements slog.LogValuer by redacting V with Marshaller.
+type Valuer struct {
+	// Marshaller is used to redact V. The default marshaller is used if nil.
+	Marshaller *render.Marshaller
+	V          interface{}
+}
+
+// Value wraps v so that it is redacted with the default marshaller when
+// logged.
+//
+// Example:
+//
+//	slog.Info("login", "user", slogredact.Value(user))
+func Value(v interface{}) Valuer {
+	return Valuer{V: v}
+}
+
+// LogValue implements slog.LogValuer.
+func (v Valuer) LogValue() slog.Value {
+	return slog.StringValue(marshallerOrDefault(v.Marshaller).Redact(v.V))
+}
+
+func marshallerOrDefault(m *render.Marshaller) *render.Marshaller {
+	if m != nil {
+		return m
+	}
+	// cannot fail without options
+	m, _ = render.NewMarshaller()
+	return m
+}
+
+// Handler is a slog.Handler middleware which redacts every attribute value,
+// including the ones nested in groups, before passing records to the inner
+// handler.
+//
+// Structs, maps, slices, arrays and pointers are replaced by their redacted
+// representation, other values are passed as is.
+type Handler struct {
+	inner      slog.Handler
+	marshaller *render.Marshaller
+}
+
+// NewHandler creates a Handler redacting attributes with m before passing
+// them to inner. The default marshaller is used if m is nil.
+func NewHandler(inner slog.Handler, m *render.Marshaller) *Handler {
+	return &Handler{
+		inner:      inner,
+		marshaller: marshallerOrDefault(m),
+	}
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.inner.Handle(ctx, redacted)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactAttr(a)
+	}
+	return &Handler{
+		inner:      h.inner.WithAttrs(redacted),
+		marshaller: h.marshaller,
+	}
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{
+		inner:      h.inner.WithGroup(name),
+		marshaller: h.marshaller,
+	}
+}
+
+func (h *Handler) redactAttr(a slog.Attr) slog.Attr {
+	return slog.Attr{Key: a.Key, Value: h.redactValue(a.Value)}
+}
+
+func (h *Handler) redactValue(v slog.Value) slog.Value {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := v.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, a := range group {
+			redacted[i] = h.redactAttr(a)
+		}
+		return slog.GroupValue(redacted...)
+
+	case slog.KindAny:
+		value := v.Any()
+		if value == nil {
+			return v
+		}
+		switch reflect.TypeOf(value).Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr:
+			return slog.StringValue(h.marshaller.Redact(value))
+		}
+	}
+	return v
+}
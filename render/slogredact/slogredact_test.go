@@ -0,0 +1,61 @@
+package slogredact
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type user struct {
+	Name     string
+	Password string `redact:"REPLACE"`
+}
+
+func newLogger(buf *bytes.Buffer) *slog.Logger {
+	inner := slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return slog.New(NewHandler(inner, nil))
+}
+
+func TestValuer(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, nil))
+	logger.Info("login", "user", Value(user{Name: "alice", Password: "hunter2"}))
+	if act := buf.String(); !strings.Contains(act, `user="slogredact.user{Name:\"alice\", Password:<redacted>}"`) {
+		t.Errorf("Unexpected log line: %s", act)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	for i, tc := range []struct {
+		log func(*slog.Logger)
+		exp string
+	}{
+		{func(l *slog.Logger) {
+			l.Info("login", "user", user{Name: "alice", Password: "hunter2"}, "count", 3)
+		}, `level=INFO msg=login user="slogredact.user{Name:\"alice\", Password:<redacted>}" count=3`},
+		{func(l *slog.Logger) {
+			l.Info("login", slog.Group("req", "user", &user{Name: "bob", Password: "hunter2"}))
+		}, `level=INFO msg=login req.user="(*slogredact.user){Name:\"bob\", Password:<redacted>}"`},
+		{func(l *slog.Logger) {
+			l.With("users", map[string]user{"a": {Password: "x"}}).WithGroup("g").Info("list", "n", "x")
+		}, `level=INFO msg=list users="map[string]slogredact.user{\"a\":slogredact.user{Name:\"\", Password:<redacted>}}" g.n=x`},
+	} {
+		buf := &bytes.Buffer{}
+		tc.log(newLogger(buf))
+		if act := strings.TrimSpace(buf.String()); act != tc.exp {
+			t.Errorf("Input #%d did not match expectations:\nExpected: %s\nActual  : %s\n", i, tc.exp, act)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package render
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Vault stores the values replaced by tokens in the TOKENIZE redact mode, so
+// that they can be recovered with Detokenize.
+//
+// Implementations must be safe for concurrent use.
+type Vault interface {
+	// Store stores a batch of values by token.
+	Store(values map[string]string) error
+	// Load returns the values of the given tokens. Unknown tokens are missing
+	// from the returned map.
+	Load(tokens []string) (map[string]string, error)
+}
+
+// WithVault lets you set the vault storing the values of the fields whose
+// redacting mode is set to "TOKENIZE". Tokens are generated while rendering
+// and stored in a single batch once the whole value has been rendered, so the
+// vault is never called from the traversal itself.
+//
+// Without vault, TOKENIZE fields are replaced like REPLACE ones, as are the
+// tokens the vault failed to store, since they could never be detokenized.
+func WithVault(vault Vault) MarshallerOption {
+	return func(m *Marshaller) error {
+		m.options.redact.vault = vault
+		return nil
+	}
+}
+
+// tokenBatch collects the tokens generated during a single call.
+type tokenBatch struct {
+	vault Vault
+	// values holds the rendered values by token.
+	values map[string]string
+	// tokens holds the tokens by rendered value, so that a value repeated in a
+	// call gets a single token.
+	tokens map[string]string
+}
+
+// tokenLength is the number of random bytes of a token.
+const tokenLength = 16
+
+var tokenRegex = regexp.MustCompile(`<tok:([0-9a-f]{32})>`)
+
+// tokenize returns the token replacing v, and adds it to the batch.
+func (o *options) tokenize(v reflect.Value) string {
+	value := o.clear().renderString(v, false)
+	b := o.tokens
+	if token, ok := b.tokens[value]; ok {
+		return "<tok:" + token + ">"
+	}
+	raw := make([]byte, tokenLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "<" + o.redact.replacementPlaceholder + ">"
+	}
+	token := hex.EncodeToString(raw)
+	if b.values == nil {
+		b.values = make(map[string]string)
+		b.tokens = make(map[string]string)
+	}
+	b.values[token] = value
+	b.tokens[value] = token
+	return "<tok:" + token + ">"
+}
+
+// flush stores the tokens generated so far in the vault.
+func (o *options) flush() error {
+	b := o.tokens
+	if b == nil || len(b.values) == 0 {
+		return nil
+	}
+	values := b.values
+	b.values, b.tokens = nil, nil
+	if err := b.vault.Store(values); err != nil {
+		return errors.Wrap(err, "cannot store tokens")
+	}
+	return nil
+}
+
+// flushString stores the tokens of s, rendered with the options, in the vault.
+// If they cannot be stored, they are replaced in s by the replacement
+// placeholder, and the error of the vault is returned.
+func (o *options) flushString(s string) (string, error) {
+	b := o.tokens
+	if b == nil || len(b.values) == 0 {
+		return s, nil
+	}
+	values := b.values
+	err := o.flush()
+	if err == nil {
+		return s, nil
+	}
+	placeholder := "<" + o.redact.replacementPlaceholder + ">"
+	replacements := make([]string, 0, 2*len(values))
+	for token := range values {
+		replacements = append(replacements, "<tok:"+token+">", placeholder)
+	}
+	return strings.NewReplacer(replacements...).Replace(s), err
+}
+
+// Detokenize replaces the tokens of s, as written by the TOKENIZE redact
+// mode, by the values they stand for, rendered as by Render. Tokens are
+// loaded from the vault in a single batch.
+//
+// An error is returned if a token is unknown to the vault.
+func Detokenize(s string, vault Vault) (string, error) {
+	matches := tokenRegex.FindAllStringSubmatch(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+	tokens := make([]string, 0, len(matches))
+	for _, match := range matches {
+		tokens = append(tokens, match[1])
+	}
+	values, err := vault.Load(tokens)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot load tokens")
+	}
+	for _, token := range tokens {
+		if _, ok := values[token]; !ok {
+			return "", errors.Errorf("unknown token %s", token)
+		}
+	}
+	return tokenRegex.ReplaceAllStringFunc(s, func(match string) string {
+		return values[tokenRegex.FindStringSubmatch(match)[1]]
+	}), nil
+}
+
+// Detokenize replaces the tokens of s by the values they stand for, using the
+// vault set with WithVault. See Detokenize for more details.
+func (m *Marshaller) Detokenize(s string) (string, error) {
+	if m.options.redact.vault == nil {
+		return "", errors.New("no vault set")
+	}
+	return Detokenize(s, m.options.redact.vault)
+}
@@ -0,0 +1,180 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type failingVault struct{}
+
+func (failingVault) Store(map[string]string) error { return errors.New("vault is down") }
+func (failingVault) Load([]string) (map[string]string, error) {
+	return nil, errors.New("vault is down")
+}
+
+func TestTokenize(t *testing.T) {
+	t.Parallel()
+
+	type card struct {
+		Number string `redact:"TOKENIZE"`
+		CVV    int    `redact:"TOKENIZE"`
+		Alias  string `redact:"TOKENIZE"`
+	}
+	cards := []card{{"4111111111111111", 123, "main"}, {"4111111111111111", 456, ""}}
+
+	vault := NewMemoryVault()
+	m, err := NewMarshaller(WithVault(vault))
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+	redacted := m.Redact(cards)
+	tokens := regexp.MustCompile(`<tok:[0-9a-f]{32}>`).FindAllString(redacted, -1)
+	if len(tokens) != 6 {
+		t.Fatalf("Expected 6 tokens, got: %s", redacted)
+	}
+	if tokens[0] != tokens[3] {
+		t.Errorf("Expected a repeated value to get a single token, got: %s", redacted)
+	}
+	if strings.Contains(redacted, "4111") {
+		t.Errorf("Value leaked: %s", redacted)
+	}
+
+	act, err := m.Detokenize(redacted)
+	if err != nil {
+		t.Fatalf("Detokenize failed: %v", err)
+	}
+	if exp := Render(cards); act != exp {
+		t.Errorf("Detokenize did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	if _, err := Detokenize(redacted, NewMemoryVault()); err == nil {
+		t.Errorf("Expected an error on unknown tokens")
+	}
+
+	// without vault, TOKENIZE behaves like REPLACE
+	assertRedactsLike(t, "without vault", cards[0], `render.card{Number:<redacted>, CVV:<redacted>, Alias:<redacted>}`)
+
+	// Diff never stores tokens
+	if act, exp := m.Diff(cards[0], cards[1]), "~ .CVV: <redacted> -> <redacted>\n~ .Alias: <redacted> -> <redacted>"; act != exp {
+		t.Errorf("Diff did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+	if act, exp := m.Diff([]card{cards[0]}, cards), "+ [1]: render.card{Number:<redacted>, CVV:<redacted>, Alias:<redacted>}"; act != exp {
+		t.Errorf("Diff did not match expectations:\nExpected: %s\nActual  : %s\n", exp, act)
+	}
+
+	copied, err := m.RedactValue(cards[0])
+	if err != nil {
+		t.Fatalf("RedactValue failed: %v", err)
+	}
+	if act, err := m.Detokenize(copied.(card).Number); err != nil || act != `"4111111111111111"` {
+		t.Errorf("Detokenize of copied value returned (%s, %v)", act, err)
+	}
+}
+
+func TestTokenizeVaultErrors(t *testing.T) {
+	t.Parallel()
+
+	type secret struct {
+		Value string `redact:"TOKENIZE"`
+	}
+	m, err := NewMarshaller(WithVault(failingVault{}))
+	if err != nil {
+		t.Fatalf("Error on creating marshaller: %v", err)
+	}
+	if act, err := m.RedactContext(context.Background(), secret{"foo"}); err == nil || act != `render.secret{Value:<redacted>}` {
+		t.Errorf("Expected RedactContext to replace the tokens and return the vault error, got (%s, %v)", act, err)
+	}
+	exp := `[]render.secret{render.secret{Value:<redacted>}, render.secret{Value:<redacted>}}`
+	for name, act := range map[string]string{
+		"Redact":    m.Redact([]secret{{"foo"}, {"bar"}}),
+		"RedactFor": m.RedactFor("audit", []secret{{"foo"}, {"bar"}}),
+	} {
+		if act != exp {
+			t.Errorf("%s did not replace the tokens which were not stored:\nExpected: %s\nActual  : %s\n", name, exp, act)
+		}
+	}
+	if act, _ := m.RedactWithReport(secret{"foo"}); act != `render.secret{Value:<redacted>}` {
+		t.Errorf("RedactWithReport did not replace the tokens which were not stored: %s", act)
+	}
+	if _, err := m.RedactContext(context.Background(), secret{}); err == nil {
+		t.Errorf("Expected RedactContext to return the vault error")
+	}
+	if err := m.RedactInPlace(&secret{"foo"}); err == nil {
+		t.Errorf("Expected RedactInPlace to return the vault error")
+	}
+	if _, err := m.Detokenize("<tok:00000000000000000000000000000000>"); err == nil {
+		t.Errorf("Expected Detokenize to return the vault error")
+	}
+}
+
+func TestFileVault(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault")
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	v, err := NewFileVault(path, key)
+	if err != nil {
+		t.Fatalf("NewFileVault failed: %v", err)
+	}
+	if err := v.Store(map[string]string{"a": `"foo"`}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Cannot read the vault file: %v", err)
+	}
+	if err := v.Store(map[string]string{"b": `"bar"`}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	// stores append to the vault file rather than rewriting it
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Cannot read the vault file: %v", err)
+	}
+	if len(after) <= len(before) || !bytes.HasPrefix(after, before) {
+		t.Errorf("Expected Store to append to the vault file")
+	}
+
+	// a record left partially written is discarded
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Cannot open the vault file: %v", err)
+	}
+	if _, err := f.Write([]byte{0, 0, 1}); err != nil {
+		t.Fatalf("Cannot write to the vault file: %v", err)
+	}
+	f.Close()
+
+	reopened, err := NewFileVault(path, key)
+	if err != nil {
+		t.Fatalf("NewFileVault failed on reopening: %v", err)
+	}
+	if err := reopened.Store(map[string]string{"c": `"baz"`}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	reopened, err = NewFileVault(path, key)
+	if err != nil {
+		t.Fatalf("NewFileVault failed on reopening: %v", err)
+	}
+	values, err := reopened.Load([]string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(values) != 3 || values["a"] != `"foo"` || values["b"] != `"bar"` || values["c"] != `"baz"` {
+		t.Errorf("Unexpected values: %v", values)
+	}
+
+	if _, err := NewFileVault(path, []byte("fedcba9876543210fedcba9876543210")); err == nil {
+		t.Errorf("Expected an error when opening the vault with another key")
+	}
+	if _, err := NewFileVault(path, []byte("short")); err == nil {
+		t.Errorf("Expected an error on an invalid key")
+	}
+}
@@ -0,0 +1,187 @@
+package render
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MemoryVault is a Vault keeping the values in memory, e.g. for tests or for
+// short lived processes.
+type MemoryVault struct {
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// NewMemoryVault creates an empty MemoryVault.
+func NewMemoryVault() *MemoryVault {
+	return &MemoryVault{values: make(map[string]string)}
+}
+
+// Store implements Vault.
+func (v *MemoryVault) Store(values map[string]string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for token, value := range values {
+		v.values[token] = value
+	}
+	return nil
+}
+
+// Load implements Vault.
+func (v *MemoryVault) Load(tokens []string) (map[string]string, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	values := make(map[string]string, len(tokens))
+	for _, token := range tokens {
+		if value, ok := v.values[token]; ok {
+			values[token] = value
+		}
+	}
+	return values, nil
+}
+
+// FileVault is a Vault persisting the values in a file encrypted with
+// AES-GCM. Each Store appends a single record holding its batch of values to
+// the file, so that storing tokens does not depend on the size of the vault.
+type FileVault struct {
+	mu     sync.Mutex
+	path   string
+	aead   cipher.AEAD
+	memory *MemoryVault
+}
+
+// recordHeaderLength is the length of the header of the records of a
+// FileVault, holding the length of the encrypted batch which follows.
+const recordHeaderLength = 4
+
+// NewFileVault opens the vault stored in the file at path, or creates it on
+// the first Store if it does not exist. The key must be 16, 24 or 32 bytes
+// long to select AES-128, AES-192 or AES-256.
+//
+// A record left partially written by an interrupted Store is discarded, the
+// Store having failed.
+func NewFileVault(path string, key []byte) (*FileVault, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid vault key")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid vault key")
+	}
+	v := &FileVault{
+		path:   path,
+		aead:   aead,
+		memory: NewMemoryVault(),
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return v, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "cannot read vault")
+	}
+	offset := 0
+	for offset < len(data) {
+		record, ok := nextRecord(data[offset:])
+		if !ok {
+			break
+		}
+		if err := v.load(record); err != nil {
+			return nil, errors.Wrapf(err, "cannot read vault %s at offset %d", path, offset)
+		}
+		offset += recordHeaderLength + len(record)
+	}
+	if offset < len(data) {
+		if err := os.Truncate(path, int64(offset)); err != nil {
+			return nil, errors.Wrapf(err, "cannot discard the partial record of vault %s", path)
+		}
+	}
+	return v, nil
+}
+
+// nextRecord returns the encrypted batch of the record at the start of data,
+// and false if the record is partial.
+func nextRecord(data []byte) ([]byte, bool) {
+	if len(data) < recordHeaderLength {
+		return nil, false
+	}
+	n := int(binary.BigEndian.Uint32(data))
+	if len(data)-recordHeaderLength < n {
+		return nil, false
+	}
+	return data[recordHeaderLength : recordHeaderLength+n], true
+}
+
+// load decrypts the batch of values of a record into memory.
+func (v *FileVault) load(record []byte) error {
+	if len(record) < v.aead.NonceSize() {
+		return errors.New("record is truncated")
+	}
+	plain, err := v.aead.Open(nil, record[:v.aead.NonceSize()], record[v.aead.NonceSize():], nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot decrypt record")
+	}
+	var values map[string]string
+	if err := json.Unmarshal(plain, &values); err != nil {
+		return errors.Wrap(err, "cannot decode record")
+	}
+	return v.memory.Store(values)
+}
+
+// Store implements Vault. The values are kept in memory only if their record
+// could be appended to the file.
+func (v *FileVault) Store(values map[string]string) error {
+	plain, err := json.Marshal(values)
+	if err != nil {
+		return errors.Wrap(err, "cannot encode vault record")
+	}
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "cannot encrypt vault record")
+	}
+	record := make([]byte, recordHeaderLength, recordHeaderLength+len(nonce)+len(plain)+v.aead.Overhead())
+	record = v.aead.Seal(append(record, nonce...), nonce, plain, nil)
+	binary.BigEndian.PutUint32(record, uint32(len(record)-recordHeaderLength))
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := appendFile(v.path, record); err != nil {
+		return errors.Wrap(err, "cannot write vault")
+	}
+	return v.memory.Store(values)
+}
+
+// Load implements Vault.
+func (v *FileVault) Load(tokens []string) (map[string]string, error) {
+	return v.memory.Load(tokens)
+}
+
+// appendFile appends data to the file at path, creating it if needed. The
+// file is truncated back to its former size if data cannot be written whole,
+// so that later records are not appended to a partial one.
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Truncate(size)
+		f.Close()
+		return err
+	}
+	return f.Close()
+}